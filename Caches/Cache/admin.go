@@ -0,0 +1,140 @@
+package cache
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// defaultAdminPath 管理接口的路径前缀
+const defaultAdminPath = "/_admin/"
+
+// GroupInfo 管理接口返回的 Group 信息
+type GroupInfo struct {
+	Name       string     `json:"name"`
+	Generation uint64     `json:"generation"`
+	Stats      Stats      `json:"stats"`
+	MainCache  CacheStats `json:"main_cache"`
+	HotCache   CacheStats `json:"hot_cache"`
+}
+
+// KeyInfo 管理接口返回的缓存项
+type KeyInfo struct {
+	Key   string `json:"key"`
+	Value []byte `json:"value,omitempty"`
+	// Expire 过期时间，为空表示永不过期
+	Expire *time.Time `json:"expire,omitempty"`
+	// Cache 缓存项所在的缓存，main 或 hot
+	Cache string `json:"cache"`
+	// NotFound 为 true 表示这是一个负缓存项
+	NotFound bool `json:"not_found,omitempty"`
+}
+
+// NewAdminHandler 返回管理接口的 http.Handler，供运维查看和清理本节点的缓存：
+//
+//	GET    /_admin/groups                    列出所有 Group
+//	GET    /_admin/groups/{group}            查看 Group 的统计信息
+//	POST   /_admin/groups/{group}/purge      清空本节点上 Group 的缓存
+//	POST   /_admin/groups/{group}/invalidate 使 Group 的所有 key 失效，并广播给其他节点
+//	GET    /_admin/groups/{group}/keys/{key} 查看本节点缓存的 key，不会触发加载
+//	DELETE /_admin/groups/{group}/keys/{key} 删除 key，会通知其归属节点
+//
+// HTTPPool 默认在 /_admin/ 下提供该接口，使用 RPCPool 时可以挂载到其他 HTTP 服务上
+func NewAdminHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /_admin/groups", func(w http.ResponseWriter, r *http.Request) {
+		mu.RLock()
+		infos := make([]GroupInfo, 0, len(groups))
+		for _, g := range groups {
+			infos = append(infos, groupInfo(g))
+		}
+		mu.RUnlock()
+		sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+		writeJSON(w, http.StatusOK, infos)
+	})
+	mux.HandleFunc("GET /_admin/groups/{group}", adminGroup(func(w http.ResponseWriter, r *http.Request, g *Group) {
+		writeJSON(w, http.StatusOK, groupInfo(g))
+	}))
+	mux.HandleFunc("POST /_admin/groups/{group}/purge", adminGroup(func(w http.ResponseWriter, r *http.Request, g *Group) {
+		g.Purge()
+		writeJSON(w, http.StatusOK, groupInfo(g))
+	}))
+	mux.HandleFunc("POST /_admin/groups/{group}/invalidate", adminGroup(func(w http.ResponseWriter, r *http.Request, g *Group) {
+		if _, err := g.Invalidate(r.Context()); err != nil {
+			writeJSON(w, http.StatusBadGateway, adminError{err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, groupInfo(g))
+	}))
+	mux.HandleFunc("GET /_admin/groups/{group}/keys/{key...}", adminGroup(func(w http.ResponseWriter, r *http.Request, g *Group) {
+		key := r.PathValue("key")
+		info, ok := g.peek(key)
+		if !ok {
+			writeJSON(w, http.StatusNotFound, adminError{"key not cached: " + key})
+			return
+		}
+		writeJSON(w, http.StatusOK, info)
+	}))
+	mux.HandleFunc("DELETE /_admin/groups/{group}/keys/{key...}", adminGroup(func(w http.ResponseWriter, r *http.Request, g *Group) {
+		if err := g.Remove(r.PathValue("key")); err != nil {
+			writeJSON(w, http.StatusBadGateway, adminError{err.Error()})
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	return mux
+}
+
+// adminError 管理接口的错误响应
+type adminError struct {
+	Error string `json:"error"`
+}
+
+// adminGroup 查找路径中的 Group，不存在时返回 404
+func adminGroup(fn func(w http.ResponseWriter, r *http.Request, g *Group)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("group")
+		g := GetGroup(name)
+		if g == nil {
+			writeJSON(w, http.StatusNotFound, adminError{"no such group: " + name})
+			return
+		}
+		fn(w, r, g)
+	}
+}
+
+func groupInfo(g *Group) GroupInfo {
+	return GroupInfo{
+		Name:       g.name,
+		Generation: g.Generation(),
+		Stats:      g.Stats(),
+		MainCache:  g.mainCache.stats(),
+		HotCache:   g.hotCache.stats(),
+	}
+}
+
+// peek 在 mainCache 和 hotCache 中查找 key，不计入统计，也不会触发加载，已失效的旧值视为不存在
+func (g *Group) peek(key string) (KeyInfo, bool) {
+	for _, c := range []struct {
+		name  string
+		cache *shardedCache
+	}{{"main", g.mainCache}, {"hot", g.hotCache}} {
+		v, ok := c.cache.peek(key)
+		if !ok || v.ver.gen < g.generation.Load() {
+			continue
+		}
+		info := KeyInfo{Key: key, Value: v.ByteSlice(), Cache: c.name, NotFound: v.notFound}
+		if !v.e.IsZero() {
+			info.Expire = &v.e
+		}
+		return info, true
+	}
+	return KeyInfo{}, false
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,80 @@
+package cache
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAdminHandler(t *testing.T) {
+	g := newTestGroup(t, "admin", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			return []byte(key), nil
+		}), WithTTL(time.Minute))
+	g.Get("jw")
+	g.Get("a/b")
+	pool := NewHTTPPool("admin")
+	srv := httptest.NewServer(pool)
+	defer srv.Close()
+
+	do := func(method, path string, status int, out interface{}) {
+		t.Helper()
+		req, _ := http.NewRequest(method, srv.URL+path, nil)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		if res.StatusCode != status {
+			t.Fatalf("%s %s: status %d, want %d", method, path, res.StatusCode, status)
+		}
+		if out != nil {
+			if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+				t.Fatalf("%s %s: %v", method, path, err)
+			}
+		}
+	}
+
+	// 默认不提供管理接口
+	do("GET", "/_admin/groups", http.StatusNotFound, nil)
+	do("DELETE", "/_admin/groups/admin/keys/jw", http.StatusNotFound, nil)
+	pool.EnableAdmin()
+
+	var infos []GroupInfo
+	do("GET", "/_admin/groups", http.StatusOK, &infos)
+	found := false
+	for _, info := range infos {
+		found = found || info.Name == "admin"
+	}
+	if !found {
+		t.Fatalf("group list %+v should contain admin", infos)
+	}
+
+	var info GroupInfo
+	do("GET", "/_admin/groups/admin", http.StatusOK, &info)
+	if info.MainCache.Items != 2 || info.Stats.Gets != 2 {
+		t.Fatalf("unexpected group info %+v", info)
+	}
+
+	// 查看 key 不计入统计，也不会触发加载
+	var key KeyInfo
+	do("GET", "/_admin/groups/admin/keys/a%2Fb", http.StatusOK, &key)
+	if string(key.Value) != "a/b" || key.Cache != "main" || key.Expire == nil {
+		t.Fatalf("unexpected key info %+v", key)
+	}
+	do("GET", "/_admin/groups/admin/keys/Sam", http.StatusNotFound, nil)
+	if s := g.Stats(); s.Gets != 2 || s.Loads != 2 {
+		t.Fatalf("peek should not touch stats, got %+v", s)
+	}
+
+	do("DELETE", "/_admin/groups/admin/keys/jw", http.StatusNoContent, nil)
+	do("GET", "/_admin/groups/admin/keys/jw", http.StatusNotFound, nil)
+
+	do("POST", "/_admin/groups/admin/purge", http.StatusOK, &info)
+	if info.MainCache.Items != 0 {
+		t.Fatalf("purge should drop all items, got %+v", info)
+	}
+	do("GET", "/_admin/groups/unknown", http.StatusNotFound, nil)
+}
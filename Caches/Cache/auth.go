@@ -0,0 +1,235 @@
+package cache
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SignatureHeader 携带请求签名的 HTTP 头，格式为 <unix 时间戳>:<随机数>:<签名>
+const SignatureHeader = "X-Cache-Signature"
+
+const (
+	// maxClockSkew 签名时间戳与本地时间允许的最大偏差，超出的请求视为过期
+	maxClockSkew = 5 * time.Minute
+	// maxNonceLen 随机数的最大长度，避免超长的随机数占用 nonceCache
+	maxNonceLen = 64
+	// maxSignedBodyBytes 校验签名时读入内存的请求体上限，超出的请求返回 413
+	maxSignedBodyBytes = 64 << 20
+)
+
+// SetTLS 使节点间的请求（包括 gossip）通过 cfg 建立 TLS 连接，并出示 cfg 中的客户端证书
+// 节点地址需使用 https://，服务端需以同样校验客户端证书的配置启动，见 MutualTLSConfig。
+// 需要在 Set、AddPeer、StartGossip 之前调用
+func (p *HTTPPool) SetTLS(cfg *tls.Config) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.tlsConfig = cfg
+	p.buildClient()
+}
+
+// SetSecret 开启请求签名：发往其他节点的请求带上以 secret 计算的 HMAC 签名，
+// ServeHTTP 拒绝签名缺失或无效的请求（/_metrics 除外）。集群内所有节点需使用相同的 secret。
+// 需要在 Set、AddPeer、StartGossip 之前调用
+func (p *HTTPPool) SetSecret(secret []byte) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.secret = secret
+	p.buildClient()
+}
+
+// buildClient 根据 TLS 配置和 secret 重新创建访问其他节点的 http.Client，调用方需持有 mu
+func (p *HTTPPool) buildClient() {
+	var rt http.RoundTripper = http.DefaultTransport
+	if p.tlsConfig != nil {
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		t.TLSClientConfig = p.tlsConfig
+		rt = t
+	}
+	if len(p.secret) > 0 {
+		rt = NewSigningTransport(rt, p.secret)
+	}
+	p.client = &http.Client{Transport: rt}
+	for _, getter := range p.httpGetters {
+		getter.client = p.client
+	}
+}
+
+// MutualTLSConfig 读取本节点的证书、私钥以及签发所有节点证书的 CA，
+// 返回双向认证的 TLS 配置：同一个配置既可用于 SetTLS，也可用于启动 HTTPS 服务，
+// 作为服务端时要求对方出示由该 CA 签发的客户端证书
+func MutualTLSConfig(certFile, keyFile, caFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("cache: no certificates found in %s", caFile)
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		RootCAs:      pool,
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+// signingTransport 为每个请求加上 HMAC 签名
+type signingTransport struct {
+	base   http.RoundTripper
+	secret []byte
+}
+
+// NewSigningTransport 返回为请求加上 HMAC-SHA256 签名的 http.RoundTripper，
+// 供 cachectl 等集群外的客户端访问开启了签名校验的节点，base 为 nil 时使用 http.DefaultTransport
+func NewSigningTransport(base http.RoundTripper, secret []byte) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &signingTransport{base: base, secret: secret}
+}
+
+func (t *signingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		b, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		body = b
+	}
+
+	// RoundTripper 不能修改调用方的请求，在副本上设置签名
+	req = req.Clone(req.Context())
+	if body != nil {
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return nil, err
+	}
+	ts, nonce := strconv.FormatInt(time.Now().Unix(), 10), hex.EncodeToString(b[:])
+	req.Header.Set(SignatureHeader, ts+":"+nonce+":"+sign(t.secret, req.Method, req.URL.RequestURI(), ts, nonce, body))
+	return t.base.RoundTrip(req)
+}
+
+// sign 计算 HMAC-SHA256(secret, method \n requestURI \n timestamp \n nonce \n hex(sha256(body)))
+func sign(secret []byte, method, uri, ts, nonce string, body []byte) string {
+	sum := sha256.Sum256(body)
+	mac := hmac.New(sha256.New, secret)
+	fmt.Fprintf(mac, "%s\n%s\n%s\n%s\n%s", method, uri, ts, nonce, hex.EncodeToString(sum[:]))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// RequireSignature 返回只放行带有合法签名的请求的 http.Handler，其余请求返回 401，
+// 用于在 HTTPPool 之外的服务器上暴露管理接口等，签名方式见 NewSigningTransport
+func RequireSignature(secret []byte, h http.Handler) http.Handler {
+	nonces := &nonceCache{}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := verifyRequest(secret, nonces, w, r); err != nil {
+			rejectRequest(w, err)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// rejectRequest 回复签名校验失败的请求，请求体过大返回 413，其余返回 401
+func rejectRequest(w http.ResponseWriter, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	http.Error(w, "unauthorized: "+err.Error(), http.StatusUnauthorized)
+}
+
+// verifyRequest 校验请求的签名，校验时会读出请求体，并替换为可以再次读取的副本
+// 时间戳超出 maxClockSkew 的请求视为过期，窗口内随机数已用过的请求视为重放；
+// 请求体超过 maxSignedBodyBytes 时返回 *http.MaxBytesError
+func verifyRequest(secret []byte, nonces *nonceCache, w http.ResponseWriter, r *http.Request) error {
+	parts := strings.SplitN(r.Header.Get(SignatureHeader), ":", 3)
+	if len(parts) != 3 {
+		return errors.New("missing signature")
+	}
+	ts, nonce, sig := parts[0], parts[1], parts[2]
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return errors.New("bad signature timestamp")
+	}
+	signedAt := time.Unix(sec, 0)
+	if skew := time.Since(signedAt); skew > maxClockSkew || skew < -maxClockSkew {
+		return errors.New("signature expired")
+	}
+	if nonce == "" || len(nonce) > maxNonceLen {
+		return errors.New("bad signature nonce")
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxSignedBodyBytes))
+	if err != nil {
+		return err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	if !hmac.Equal([]byte(sig), []byte(sign(secret, r.Method, r.RequestURI, ts, nonce, body))) {
+		return errors.New("bad signature")
+	}
+	// 签名合法后才记录随机数，伪造的请求不会占用 nonceCache
+	if !nonces.use(nonce, signedAt.Add(maxClockSkew)) {
+		return errors.New("replayed request")
+	}
+	return nil
+}
+
+// nonceCache 记录签名时间窗口内已经用过的随机数，零值可以直接使用
+type nonceCache struct {
+	mu        sync.Mutex
+	seen      map[string]time.Time // 随机数 -> 时间戳离开窗口的时间
+	lastSweep time.Time
+}
+
+// use 记录随机数，窗口内已经用过时返回 false
+// 时间戳离开窗口的请求会因过期被拒绝，对应的记录不再需要，惰性地清理
+func (c *nonceCache) use(nonce string, expire time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(c.lastSweep) >= sweepInterval {
+		for n, e := range c.seen {
+			if now.After(e) {
+				delete(c.seen, n)
+			}
+		}
+		c.lastSweep = now
+	}
+	if c.seen == nil {
+		c.seen = make(map[string]time.Time)
+	}
+	if e, ok := c.seen[nonce]; ok && !now.After(e) {
+		return false
+	}
+	c.seen[nonce] = expire
+	return true
+}
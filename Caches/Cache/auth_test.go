@@ -0,0 +1,197 @@
+package cache
+
+import (
+	"bytes"
+	pb "cache/cachepb"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeTestCerts 生成一个 CA 以及由其签发的 127.0.0.1/localhost 证书，
+// 证书同时可用于服务端和客户端认证，返回证书、私钥和 CA 的文件路径
+func writeTestCerts(t *testing.T) (certFile, keyFile, caFile string) {
+	t.Helper()
+	dir := t.TempDir()
+	newKey := func() *ecdsa.PrivateKey {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key
+	}
+	writePEM := func(name, typ string, der []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	caKey := newKey()
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "cache test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, _ := x509.ParseCertificate(caDER)
+
+	key := newKey()
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "cache peer"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return writePEM("peer.pem", "CERTIFICATE", der), writePEM("peer.key", "EC PRIVATE KEY", keyDER), writePEM("ca.pem", "CERTIFICATE", caDER)
+}
+
+func TestMutualTLS(t *testing.T) {
+	newTestGroup(t, "tls", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return []byte(key), nil
+	}))
+	cfg, err := MutualTLSConfig(writeTestCerts(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewUnstartedServer(NewHTTPPool("tls"))
+	srv.TLS = cfg
+	srv.StartTLS()
+	defer srv.Close()
+
+	pool := NewHTTPPool("self")
+	pool.SetTLS(cfg)
+	pool.Set(srv.URL)
+	res := &pb.Response{}
+	if err := pool.httpGetters[srv.URL].Get(context.Background(), &pb.Request{Group: "tls", Key: "jw"}, res); err != nil || string(res.Value) != "jw" {
+		t.Fatalf("Get over mutual TLS got %q, err %v", res.Value, err)
+	}
+
+	// 不出示客户端证书的请求在握手阶段被拒绝
+	noCert := cfg.Clone()
+	noCert.Certificates = nil
+	pool.SetTLS(noCert)
+	if err := pool.httpGetters[srv.URL].Get(context.Background(), &pb.Request{Group: "tls", Key: "jw"}, &pb.Response{}); err == nil {
+		t.Fatalf("request without client certificate should fail")
+	}
+}
+
+func TestSignedRequests(t *testing.T) {
+	newTestGroup(t, "signed", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return []byte(key), nil
+	}))
+	server := NewHTTPPool("signed")
+	server.SetSecret([]byte("secret"))
+	srv := httptest.NewServer(server)
+	defer srv.Close()
+
+	get := func(secret string) error {
+		pool := NewHTTPPool("self")
+		if secret != "" {
+			pool.SetSecret([]byte(secret))
+		}
+		pool.Set(srv.URL)
+		return pool.httpGetters[srv.URL].Get(context.Background(), &pb.Request{Group: "signed", Key: "jw"}, &pb.Response{})
+	}
+	if err := get("secret"); err != nil {
+		t.Fatalf("signed request failed: %v", err)
+	}
+	for _, secret := range []string{"", "wrong"} {
+		if err := get(secret); err == nil || !strings.Contains(err.Error(), "401") {
+			t.Fatalf("request signed with %q should be rejected, got %v", secret, err)
+		}
+	}
+
+	// 统计信息不要求签名
+	if res, err := http.Get(srv.URL + defaultMetricsPath); err != nil || res.StatusCode != http.StatusOK {
+		t.Fatalf("metrics should be public, got %v", err)
+	}
+
+	// 请求体被篡改或签名过期
+	secret := []byte("secret")
+	signed := func(method, uri, ts, nonce string, body []byte) string {
+		return ts + ":" + nonce + ":" + sign(secret, method, uri, ts, nonce, body)
+	}
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	req := httptest.NewRequest("PUT", "/_cache/signed/jw", strings.NewReader("tampered"))
+	req.Header.Set(SignatureHeader, signed("PUT", "/_cache/signed/jw", ts, "n1", []byte("original")))
+	if err := verifyRequest(secret, &nonceCache{}, httptest.NewRecorder(), req); err == nil {
+		t.Fatalf("tampered body should be rejected")
+	}
+	old := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+	req = httptest.NewRequest("GET", "/_cache/signed/jw", nil)
+	req.Header.Set(SignatureHeader, signed("GET", "/_cache/signed/jw", old, "n2", nil))
+	if err := verifyRequest(secret, &nonceCache{}, httptest.NewRecorder(), req); err == nil {
+		t.Fatalf("expired signature should be rejected")
+	}
+
+	// 时间窗口内重放同一个请求
+	nonces := &nonceCache{}
+	header := signed("GET", "/_cache/signed/jw", ts, "n3", nil)
+	for i, want := range []bool{true, false} {
+		req = httptest.NewRequest("GET", "/_cache/signed/jw", nil)
+		req.Header.Set(SignatureHeader, header)
+		if err := verifyRequest(secret, nonces, httptest.NewRecorder(), req); (err == nil) != want {
+			t.Fatalf("request #%d: err %v, want accepted %v", i, err, want)
+		}
+	}
+}
+
+func TestRequireSignature(t *testing.T) {
+	srv := httptest.NewServer(RequireSignature([]byte("secret"), NewAdminHandler()))
+	defer srv.Close()
+
+	if res, err := http.Get(srv.URL + defaultAdminPath + "groups"); err != nil || res.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("unsigned admin request should be rejected, got %v", err)
+	}
+	client := &http.Client{Transport: NewSigningTransport(nil, []byte("secret"))}
+	for i := 0; i < 2; i++ {
+		if res, err := client.Get(srv.URL + defaultAdminPath + "groups"); err != nil || res.StatusCode != http.StatusOK {
+			t.Fatalf("signed admin request #%d failed: %v", i, err)
+		}
+	}
+
+	// 超大的请求体在校验签名前就被拒绝
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", defaultAdminPath+"groups/jw/purge", bytes.NewReader(make([]byte, maxSignedBodyBytes+1)))
+	req.Header.Set(SignatureHeader, strconv.FormatInt(time.Now().Unix(), 10)+":n1:sig")
+	RequireSignature([]byte("secret"), NewAdminHandler()).ServeHTTP(rec, req)
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("oversized body should return 413, got %d", rec.Code)
+	}
+}
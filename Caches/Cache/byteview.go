@@ -1,25 +1,80 @@
 package cache
 
+import (
+	"fmt"
+	"time"
+)
+
 // ByteView 提供对底层字节切片的只读视图，避免被外部修改
 type ByteView struct {
 	b []byte
+	e time.Time // 过期时间，零值表示永不过期
+	// notFound 为 true 表示这是一个负缓存项：Getter 确认 key 不存在
+	notFound bool
+	// compressed 为 true 表示 b 是 gzip 压缩后的数据，n 为原始数据的长度，见 WithCompression
+	compressed bool
+	n          int
+	// ver 开始加载该值时的版本，见 version
+	ver version
+}
+
+// version 缓存项的版本，用于丢弃已经过时的写入
+type version struct {
+	// gen Group 的代数，小于当前代数的值视为已失效，见 Group.Invalidate
+	gen uint64
+	// seq key 所在分段的写序号，Set、Remove 之前开始的加载不再写入缓存
+	seq uint64
+}
+
+// Expire 返回缓存值的过期时间，零值表示永不过期
+func (v ByteView) Expire() time.Time {
+	return v.e
+}
+
+// ttl 返回缓存值剩余的存活时间，0 表示永不过期
+func (v ByteView) ttl() time.Duration {
+	if v.e.IsZero() {
+		return 0
+	}
+	// 已经过期的值至少保留 1ns，避免被当作永不过期
+	return max(time.Until(v.e), 1)
 }
 
 // Len 返回当前视图包含的字节长度，实现lru.Value接口
+// 压缩存储的值同样返回原始数据的长度
 func (v ByteView) Len() int {
+	if v.compressed {
+		return v.n
+	}
 	return len(v.b)
 }
 
 // ByteSlice 返回当前视图的字节切片拷贝，防止外部修改原始数据
+// 压缩存储的值返回解压后的数据
 func (v ByteView) ByteSlice() []byte {
+	if v.compressed {
+		return v.uncompressed()
+	}
 	return cloneBytes(v.b)
 }
 
 //String 将字节内容转换成字符串
 func (v ByteView) String() string {
+	if v.compressed {
+		return string(v.uncompressed())
+	}
 	return string(v.b)
 }
 
+// uncompressed 解压 b，数据只由 Group.compress 生成，解压失败说明内存中的数据已损坏
+func (v ByteView) uncompressed() []byte {
+	b, err := gunzipBytes(v.b)
+	if err != nil {
+		panic(fmt.Sprintf("cache: corrupt compressed value: %v", err))
+	}
+	return b
+}
+
 // cloneBytes 返回一个字节切片的拷贝
 func cloneBytes(b []byte) []byte {
 	c := make([]byte, len(b))
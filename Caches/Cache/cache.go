@@ -3,24 +3,100 @@ package cache
 
 import (
 	"cache/lru"
+	"hash/fnv"
 	"sync"
+	"time"
+)
+
+const (
+	// sweepInterval 两次惰性清理过期节点之间的最小间隔
+	sweepInterval = time.Minute
+	// defaultShards 默认的最大分片数
+	defaultShards = 16
+	// minShardBytes 每个分片至少分到的容量，容量太小的缓存不再分片
+	minShardBytes = 1 << 10
 )
 
 type cache struct {
 	mu         sync.Mutex // 保护并发访问的互斥锁
-	lru        *lru.Cache // 底层的 LRU 缓存
+	store      store      // 底层存储，由 policy 决定淘汰策略
+	policy     Policy     // 淘汰策略，默认为 LRU
 	cacheBytes int64      // 最大缓存大小
+	lastSweep  time.Time  // 上一次清理过期节点的时间
+
+	// 统计信息，均受 mu 保护
+	nget   int64
+	nhit   int64
+	nevict int64
 }
 
-// add
-func (c *cache) add(key string, value lru.Value) {
+// CacheStats 描述单个缓存（mainCache 或 hotCache）的使用情况
+type CacheStats struct {
+	Bytes     int64 // 当前占用字节数
+	Items     int64 // 当前缓存项数量
+	Gets      int64 // 查询次数
+	Hits      int64 // 命中次数
+	Evictions int64 // 淘汰次数（含过期清理和删除）
+}
+
+// stats 返回当前缓存的统计快照
+func (c *cache) stats() CacheStats {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	if c.lru == nil {
-		c.lru = lru.New(c.cacheBytes, nil)
+	s := CacheStats{
+		Gets:      c.nget,
+		Hits:      c.nhit,
+		Evictions: c.nevict,
+	}
+	if c.store != nil {
+		s.Bytes = c.store.Bytes()
+		s.Items = int64(c.store.Len())
+	}
+	return s
+}
+
+// lazyInit 首次写入时创建底层存储，调用方需持有 mu
+func (c *cache) lazyInit() {
+	if c.store == nil {
+		c.store = newStore(c.policy, c.cacheBytes, func(string, lru.Value) {
+			c.nevict++
+		})
+	}
+}
+
+// entry 存入底层 store 的值，容量按实际占用的内存计算：
+// 压缩存储的值按压缩后的长度计入，ByteView.Len 仍返回原始数据的长度
+type entry struct {
+	ByteView
+}
+
+func (e entry) Len() int {
+	return len(e.b)
+}
+
+// add 写入缓存，expire 为零值表示永不过期
+// 顺带惰性地清理过期节点，避免过期数据长期占用内存
+func (c *cache) add(key string, value ByteView, expire time.Time) {
+	c.addIf(key, value, expire, nil)
+}
+
+// addIf 与 add 相同，但在持有锁时先调用 valid，返回 false 时放弃写入
+// 写入方在删除前先使 valid 失效，之后的 addIf 就不会把旧值写回
+func (c *cache) addIf(key string, value ByteView, expire time.Time, valid func() bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if valid != nil && !valid() {
+		return
+	}
+	c.lazyInit()
+	c.store.AddWithExpire(key, entry{value}, expire)
+
+	if now := time.Now(); now.Sub(c.lastSweep) >= sweepInterval {
+		c.store.RemoveExpired()
+		c.lastSweep = now
 	}
-	c.lru.Add(key, value)
 }
 
 // get
@@ -28,13 +104,152 @@ func (c *cache) get(key string) (value ByteView, ok bool) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	if c.lru == nil {
+	c.nget++
+	if c.store == nil {
 		return
 	}
 
-	if v, ok := c.lru.Get(key); ok {
-		return v.(ByteView), true
+	if v, ok := c.store.Get(key); ok {
+		c.nhit++
+		return v.(entry).ByteView, true
 	}
 
 	return
 }
+
+// peek 查找缓存但不计入命中统计，也不影响淘汰顺序
+func (c *cache) peek(key string) (value ByteView, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.store == nil {
+		return
+	}
+	if v, ok := c.store.Peek(key); ok {
+		return v.(entry).ByteView, ok
+	}
+	return
+}
+
+// purge 丢弃所有缓存项
+func (c *cache) purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.store = nil
+}
+
+// rangeEntries 遍历所有未过期的缓存项，遍历期间持有锁，fn 中不能再访问该 cache
+func (c *cache) rangeEntries(fn func(key string, value ByteView, expire time.Time) bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.store == nil {
+		return
+	}
+	c.store.Range(func(key string, value lru.Value, expire time.Time) bool {
+		return fn(key, value.(entry).ByteView, expire)
+	})
+}
+
+// remove
+func (c *cache) remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.store == nil {
+		return
+	}
+	c.store.Remove(key)
+}
+
+// shardedCache 将缓存按 key 的哈希分成多个互相独立的分片，每个分片各有一把锁，
+// 不同分片上的读写可以并行，避免单个互斥锁成为多核下的瓶颈。
+// 总容量平均分给各分片，每个分片内部各自按淘汰策略淘汰；
+// 分片之间不借用容量，大于 cacheBytes/shards 的缓存项写入后立即被淘汰
+type shardedCache struct {
+	shards []*cache
+}
+
+// newShardedCache 创建分片缓存，shards 必须是 2 的幂，各分片均按 policy 淘汰
+func newShardedCache(cacheBytes int64, shards int, policy Policy) *shardedCache {
+	c := &shardedCache{shards: make([]*cache, shards)}
+	for i := range c.shards {
+		c.shards[i] = &cache{cacheBytes: cacheBytes / int64(shards), policy: policy}
+	}
+	return c
+}
+
+// shardCount 根据容量选择分片数：不超过 defaultShards，且每个分片不少于 minShardBytes
+// cacheBytes 为 0 表示不限容量，使用 defaultShards 个分片
+func shardCount(cacheBytes int64) int {
+	n := 1
+	for n < defaultShards && (cacheBytes == 0 || cacheBytes/int64(n*2) >= minShardBytes) {
+		n *= 2
+	}
+	return n
+}
+
+// shard 返回 key 所在的分片
+func (c *shardedCache) shard(key string) *cache {
+	if len(c.shards) == 1 {
+		return c.shards[0]
+	}
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return c.shards[h.Sum32()&uint32(len(c.shards)-1)]
+}
+
+func (c *shardedCache) add(key string, value ByteView, expire time.Time) {
+	c.shard(key).add(key, value, expire)
+}
+
+func (c *shardedCache) addIf(key string, value ByteView, expire time.Time, valid func() bool) {
+	c.shard(key).addIf(key, value, expire, valid)
+}
+
+func (c *shardedCache) get(key string) (ByteView, bool) {
+	return c.shard(key).get(key)
+}
+
+func (c *shardedCache) remove(key string) {
+	c.shard(key).remove(key)
+}
+
+func (c *shardedCache) peek(key string) (ByteView, bool) {
+	return c.shard(key).peek(key)
+}
+
+func (c *shardedCache) purge() {
+	for _, shard := range c.shards {
+		shard.purge()
+	}
+}
+
+// rangeEntries 依次遍历各分片，fn 返回 false 时停止遍历
+func (c *shardedCache) rangeEntries(fn func(key string, value ByteView, expire time.Time) bool) {
+	for _, shard := range c.shards {
+		stopped := false
+		shard.rangeEntries(func(key string, value ByteView, expire time.Time) bool {
+			stopped = !fn(key, value, expire)
+			return !stopped
+		})
+		if stopped {
+			return
+		}
+	}
+}
+
+// stats 汇总所有分片的统计信息
+func (c *shardedCache) stats() CacheStats {
+	var s CacheStats
+	for _, shard := range c.shards {
+		cs := shard.stats()
+		s.Bytes += cs.Bytes
+		s.Items += cs.Items
+		s.Gets += cs.Gets
+		s.Hits += cs.Hits
+		s.Evictions += cs.Evictions
+	}
+	return s
+}
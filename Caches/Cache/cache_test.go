@@ -0,0 +1,93 @@
+package cache
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestShardCount(t *testing.T) {
+	testCases := map[int64]int{
+		0:       defaultShards,
+		512:     1,
+		2 << 10: 2,
+		1 << 20: defaultShards,
+	}
+	for cacheBytes, want := range testCases {
+		if got := shardCount(cacheBytes); got != want {
+			t.Errorf("shardCount(%d) = %d, want %d", cacheBytes, got, want)
+		}
+	}
+}
+
+func TestShardedCache(t *testing.T) {
+	c := newShardedCache(16<<10, 16, LRU)
+	for i := 0; i < 100; i++ {
+		key := strconv.Itoa(i)
+		c.add(key, ByteView{b: []byte(key)}, time.Time{})
+	}
+	for i := 0; i < 100; i++ {
+		key := strconv.Itoa(i)
+		if v, ok := c.get(key); !ok || v.String() != key {
+			t.Fatalf("sharded cache miss %s", key)
+		}
+	}
+	c.remove("42")
+	if _, ok := c.get("42"); ok {
+		t.Fatalf("removed key should miss")
+	}
+
+	s := c.stats()
+	if s.Items != 99 || s.Gets != 101 || s.Hits != 100 {
+		t.Fatalf("unexpected stats %+v", s)
+	}
+	used := 0
+	for _, shard := range c.shards {
+		if shard.stats().Items > 0 {
+			used++
+		}
+	}
+	if used < 8 {
+		t.Fatalf("keys should spread across shards, only %d used", used)
+	}
+}
+
+// TestShardShareLimit 单个缓存项的上限是一个分片的容量，而不是总容量
+func TestShardShareLimit(t *testing.T) {
+	c := newShardedCache(16<<10, 16, LRU)
+	share := 16 << 10 / 16
+
+	c.add("small", ByteView{b: make([]byte, share/2)}, time.Time{})
+	if _, ok := c.get("small"); !ok {
+		t.Fatalf("value within one shard's share should be cached")
+	}
+	c.add("large", ByteView{b: make([]byte, share*2)}, time.Time{})
+	if _, ok := c.get("large"); ok {
+		t.Fatalf("value larger than one shard's share should not be cached")
+	}
+	if s := c.stats(); s.Items != 1 || s.Bytes > int64(share) {
+		t.Fatalf("unexpected stats %+v", s)
+	}
+}
+
+// BenchmarkCacheGet 并发读取同一批 key，比较单锁与分片的吞吐量
+// 使用 -cpu 1,2,4,8 观察吞吐量随 GOMAXPROCS 的变化
+func BenchmarkCacheGet(b *testing.B) {
+	const keys = 1024
+	for _, shards := range []int{1, defaultShards} {
+		b.Run(fmt.Sprintf("shards=%d", shards), func(b *testing.B) {
+			c := newShardedCache(0, shards, LRU)
+			for i := 0; i < keys; i++ {
+				c.add(strconv.Itoa(i), ByteView{b: []byte("value")}, time.Time{})
+			}
+			b.RunParallel(func(pb *testing.PB) {
+				i := 0
+				for pb.Next() {
+					c.get(strconv.Itoa(i % keys))
+					i++
+				}
+			})
+		})
+	}
+}
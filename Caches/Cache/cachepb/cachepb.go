@@ -0,0 +1,340 @@
+// Package cachepb 定义节点间通信使用的消息格式
+//
+// 每条消息以 1 字节版本号开头，随后按固定顺序编码各字段：
+// 字符串和字节切片使用 uvarint 长度前缀，整数使用 varint。
+// 新增字段只能追加在末尾，并提升 Version：解码时接受不低于 MinVersion 的任意版本，
+// 较新版本追加的未知字段被忽略，较旧版本缺少的字段保持零值。
+// 批量响应中的每个 Response 带有长度前缀，因此其末尾的未知字段同样可以跳过。
+package cachepb
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	// Version 当前消息格式版本，版本 2 在各消息末尾追加了 Gen
+	Version byte = 2
+	// MinVersion 能够解码的最低版本，不兼容的格式改动需要同时提升 MinVersion
+	MinVersion byte = 1
+)
+
+// ContentType HTTP 传输时使用的 Content-Type
+const ContentType = "application/x-cache-pb"
+
+// Code 表示远程节点处理请求的结果
+type Code uint8
+
+const (
+	CodeOK Code = iota
+	CodeBadRequest
+	CodeNotFound
+	CodeInternal
+	// CodeKeyNotFound Getter 确认 key 不存在，与 CodeNotFound（Group 不存在）区分
+	CodeKeyNotFound
+)
+
+func (c Code) String() string {
+	switch c {
+	case CodeOK:
+		return "ok"
+	case CodeBadRequest:
+		return "bad request"
+	case CodeNotFound:
+		return "not found"
+	case CodeInternal:
+		return "internal error"
+	case CodeKeyNotFound:
+		return "key not found"
+	default:
+		return fmt.Sprintf("code(%d)", uint8(c))
+	}
+}
+
+// Request 节点间的请求
+type Request struct {
+	Group string
+	Key   string
+	Value []byte
+	// TTL 写入时缓存项的存活时间，0 表示永不过期
+	TTL time.Duration
+	// Gen 请求方 Group 的代数，接收方推进到两者中的较大值（版本 2 起）
+	Gen uint64
+}
+
+// Response 节点间的响应
+type Response struct {
+	Code  Code
+	Error string // Code 不为 CodeOK 时的错误描述
+	Value []byte
+	// TTL 缓存项剩余的存活时间，0 表示永不过期
+	// Code 为 CodeKeyNotFound 时表示请求方可以缓存该结果的时间，0 表示不要缓存
+	TTL time.Duration
+	// Gen 响应方 Group 的代数，请求方推进到两者中的较大值（版本 2 起）
+	Gen uint64
+}
+
+// BatchRequest 节点间的批量读请求
+type BatchRequest struct {
+	Group string
+	Keys  []string
+	Gen   uint64 // 同 Request.Gen
+}
+
+// BatchResponse 节点间的批量读响应，Responses 与请求的 Keys 一一对应
+// Code 不为 CodeOK 表示整个请求失败（如 Group 不存在），此时 Responses 为空
+type BatchResponse struct {
+	Code      Code
+	Error     string
+	Responses []Response
+	Gen       uint64 // 同 Response.Gen
+}
+
+// Error 表示远程节点返回的错误，用于与网络等传输错误区分
+type Error struct {
+	Code    Code
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("peer returned %v: %s", e.Code, e.Message)
+}
+
+// Err 将 Response 转换为 error，Code 为 CodeOK 时返回 nil
+func (r *Response) Err() error {
+	if r.Code == CodeOK {
+		return nil
+	}
+	return &Error{Code: r.Code, Message: r.Error}
+}
+
+// Err 将 BatchResponse 转换为 error，Code 为 CodeOK 时返回 nil
+// 单个 key 的错误由对应 Response 的 Err 返回
+func (r *BatchResponse) Err() error {
+	if r.Code == CodeOK {
+		return nil
+	}
+	return &Error{Code: r.Code, Message: r.Error}
+}
+
+var (
+	// ErrVersion 消息版本不受支持
+	ErrVersion = errors.New("cachepb: unsupported version")
+	// ErrTruncated 消息不完整
+	ErrTruncated = errors.New("cachepb: truncated message")
+)
+
+// Marshal 编码请求
+func (r *Request) Marshal() []byte {
+	b := []byte{Version}
+	b = appendBytes(b, []byte(r.Group))
+	b = appendBytes(b, []byte(r.Key))
+	b = appendBytes(b, r.Value)
+	b = binary.AppendVarint(b, int64(r.TTL))
+	b = binary.AppendUvarint(b, r.Gen)
+	return b
+}
+
+// Unmarshal 解码请求
+func (r *Request) Unmarshal(data []byte) error {
+	d := decoder{b: data}
+	d.version()
+	r.Group = string(d.bytes())
+	r.Key = string(d.bytes())
+	r.Value = d.bytes()
+	r.TTL = time.Duration(d.varint())
+	r.Gen = d.optionalUvarint()
+	return d.err
+}
+
+// Marshal 编码响应
+func (r *Response) Marshal() []byte {
+	return r.append([]byte{Version})
+}
+
+// Unmarshal 解码响应
+func (r *Response) Unmarshal(data []byte) error {
+	d := decoder{b: data}
+	d.version()
+	d.response(r)
+	return d.err
+}
+
+// MarshalAround 编码除 Value 以外的字段，供调用方另行写出长度为 n 的 Value（如已压缩存储的数据）：
+// head、Value、tail 依次拼接即为 Marshal 的结果
+func (r *Response) MarshalAround(n int) (head, tail []byte) {
+	return r.appendHead([]byte{Version}, n), r.appendTail(nil)
+}
+
+// append 将响应的各字段（不含版本号）追加到 b
+func (r *Response) append(b []byte) []byte {
+	b = r.appendHead(b, len(r.Value))
+	b = append(b, r.Value...)
+	return r.appendTail(b)
+}
+
+// appendHead 追加 Value 之前的字段，以长度为 n 的 Value 的长度前缀结尾
+func (r *Response) appendHead(b []byte, n int) []byte {
+	b = append(b, byte(r.Code))
+	b = appendBytes(b, []byte(r.Error))
+	return binary.AppendUvarint(b, uint64(n))
+}
+
+// appendTail 追加 Value 之后的字段
+func (r *Response) appendTail(b []byte) []byte {
+	b = binary.AppendVarint(b, int64(r.TTL))
+	return binary.AppendUvarint(b, r.Gen)
+}
+
+// Marshal 编码批量请求
+func (r *BatchRequest) Marshal() []byte {
+	b := []byte{Version}
+	b = appendBytes(b, []byte(r.Group))
+	b = binary.AppendUvarint(b, uint64(len(r.Keys)))
+	for _, key := range r.Keys {
+		b = appendBytes(b, []byte(key))
+	}
+	b = binary.AppendUvarint(b, r.Gen)
+	return b
+}
+
+// Unmarshal 解码批量请求
+func (r *BatchRequest) Unmarshal(data []byte) error {
+	d := decoder{b: data}
+	d.version()
+	r.Group = string(d.bytes())
+	r.Keys = nil
+	for n := d.count(); n > 0 && d.err == nil; n-- {
+		r.Keys = append(r.Keys, string(d.bytes()))
+	}
+	r.Gen = d.optionalUvarint()
+	return d.err
+}
+
+// Marshal 编码批量响应
+func (r *BatchResponse) Marshal() []byte {
+	b := []byte{Version, byte(r.Code)}
+	b = appendBytes(b, []byte(r.Error))
+	b = binary.AppendUvarint(b, uint64(len(r.Responses)))
+	for i := range r.Responses {
+		b = appendBytes(b, r.Responses[i].append(nil))
+	}
+	b = binary.AppendUvarint(b, r.Gen)
+	return b
+}
+
+// Unmarshal 解码批量响应
+func (r *BatchResponse) Unmarshal(data []byte) error {
+	d := decoder{b: data}
+	d.version()
+	r.Code = Code(d.byte())
+	r.Error = string(d.bytes())
+	r.Responses = nil
+	for n := d.count(); n > 0 && d.err == nil; n-- {
+		var res Response
+		sub := decoder{b: d.bytes(), err: d.err}
+		sub.response(&res)
+		d.err = sub.err
+		r.Responses = append(r.Responses, res)
+	}
+	r.Gen = d.optionalUvarint()
+	return d.err
+}
+
+func appendBytes(b, v []byte) []byte {
+	b = binary.AppendUvarint(b, uint64(len(v)))
+	return append(b, v...)
+}
+
+// decoder 顺序读取各字段，遇到第一个错误后后续读取均为空操作
+type decoder struct {
+	b   []byte
+	err error
+}
+
+func (d *decoder) version() {
+	if v := d.byte(); d.err == nil && v < MinVersion {
+		d.err = fmt.Errorf("%w: %d", ErrVersion, v)
+	}
+}
+
+func (d *decoder) byte() byte {
+	if d.err != nil {
+		return 0
+	}
+	if len(d.b) < 1 {
+		d.err = ErrTruncated
+		return 0
+	}
+	v := d.b[0]
+	d.b = d.b[1:]
+	return v
+}
+
+func (d *decoder) bytes() []byte {
+	if d.err != nil {
+		return nil
+	}
+	n, size := binary.Uvarint(d.b)
+	if size <= 0 || uint64(len(d.b)-size) < n {
+		d.err = ErrTruncated
+		return nil
+	}
+	v := d.b[size : size+int(n)]
+	d.b = d.b[size+int(n):]
+	if len(v) == 0 {
+		return nil
+	}
+	return v
+}
+
+func (d *decoder) response(r *Response) {
+	r.Code = Code(d.byte())
+	r.Error = string(d.bytes())
+	r.Value = d.bytes()
+	r.TTL = time.Duration(d.varint())
+	r.Gen = d.optionalUvarint()
+}
+
+// count 读取元素个数，个数超过剩余字节数时视为消息不完整（每个元素至少占 1 字节）
+func (d *decoder) count() int {
+	if d.err != nil {
+		return 0
+	}
+	n, size := binary.Uvarint(d.b)
+	if size <= 0 || n > uint64(len(d.b)-size) {
+		d.err = ErrTruncated
+		return 0
+	}
+	d.b = d.b[size:]
+	return int(n)
+}
+
+// optionalUvarint 读取较新版本追加的字段，旧版本的消息没有该字段时返回 0
+func (d *decoder) optionalUvarint() uint64 {
+	if d.err != nil || len(d.b) == 0 {
+		return 0
+	}
+	v, size := binary.Uvarint(d.b)
+	if size <= 0 {
+		d.err = ErrTruncated
+		return 0
+	}
+	d.b = d.b[size:]
+	return v
+}
+
+func (d *decoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	v, size := binary.Varint(d.b)
+	if size <= 0 {
+		d.err = ErrTruncated
+		return 0
+	}
+	d.b = d.b[size:]
+	return v
+}
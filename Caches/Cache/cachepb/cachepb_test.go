@@ -0,0 +1,115 @@
+package cachepb
+
+import (
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRequestRoundTrip(t *testing.T) {
+	in := &Request{Group: "scores", Key: "Tom", Value: []byte("630"), TTL: time.Minute, Gen: 3}
+	out := &Request{}
+	if err := out.Unmarshal(in.Marshal()); err != nil || !reflect.DeepEqual(in, out) {
+		t.Fatalf("round trip got %+v, err %v", out, err)
+	}
+}
+
+func TestResponseRoundTrip(t *testing.T) {
+	in := &Response{Code: CodeNotFound, Error: "no such group: scores"}
+	out := &Response{}
+	if err := out.Unmarshal(in.Marshal()); err != nil || !reflect.DeepEqual(in, out) {
+		t.Fatalf("round trip got %+v, err %v", out, err)
+	}
+
+	var e *Error
+	if err := out.Err(); !errors.As(err, &e) || e.Code != CodeNotFound {
+		t.Fatalf("Err should return *Error with CodeNotFound, got %v", err)
+	}
+}
+
+func TestMarshalAround(t *testing.T) {
+	in := &Response{Value: []byte("630"), TTL: time.Minute, Gen: 3}
+	head, tail := in.MarshalAround(len(in.Value))
+	data := append(append(head, in.Value...), tail...)
+	if want := in.Marshal(); string(data) != string(want) {
+		t.Fatalf("MarshalAround = %x, want %x", data, want)
+	}
+}
+
+func TestUnmarshalInvalid(t *testing.T) {
+	data := (&Response{Value: []byte("630")}).Marshal()
+
+	if err := (&Response{}).Unmarshal(data[:len(data)-2]); !errors.Is(err, ErrTruncated) {
+		t.Fatalf("truncated message should fail, got %v", err)
+	}
+
+	data[0] = MinVersion - 1
+	if err := (&Response{}).Unmarshal(data); !errors.Is(err, ErrVersion) {
+		t.Fatalf("version below MinVersion should fail, got %v", err)
+	}
+}
+
+// 版本 1 的消息没有 Gen，解码为 0
+func TestUnmarshalOlderVersion(t *testing.T) {
+	b := []byte{1}
+	b = appendBytes(b, []byte("scores"))
+	b = appendBytes(b, []byte("Tom"))
+	b = appendBytes(b, nil)
+	b = binary.AppendVarint(b, int64(time.Minute))
+	out := &Request{}
+	if err := out.Unmarshal(b); err != nil || out.Key != "Tom" || out.TTL != time.Minute || out.Gen != 0 {
+		t.Fatalf("version 1 request got %+v, err %v", out, err)
+	}
+}
+
+// 较新版本在末尾追加的字段被忽略
+func TestUnmarshalNewerVersion(t *testing.T) {
+	in := &Request{Group: "scores", Key: "Tom", TTL: time.Minute}
+	data := append(in.Marshal(), 1, 2, 3)
+	data[0] = Version + 1
+	out := &Request{}
+	if err := out.Unmarshal(data); err != nil || !reflect.DeepEqual(in, out) {
+		t.Fatalf("newer request got %+v, err %v", out, err)
+	}
+
+	// 模拟新版本的批量响应：每个 Response 末尾多出一个字段
+	res := &BatchResponse{Responses: []Response{
+		{Value: []byte("630")},
+		{Code: CodeKeyNotFound, Error: "Jack not exist"},
+	}, Gen: 3}
+	b := []byte{Version + 1, byte(res.Code)}
+	b = appendBytes(b, nil)
+	b = binary.AppendUvarint(b, uint64(len(res.Responses)))
+	for i := range res.Responses {
+		b = appendBytes(b, binary.AppendUvarint(res.Responses[i].append(nil), 42))
+	}
+	b = binary.AppendUvarint(b, res.Gen)
+	got := &BatchResponse{}
+	if err := got.Unmarshal(append(b, 7)); err != nil || !reflect.DeepEqual(res, got) {
+		t.Fatalf("newer batch got %+v, err %v", got, err)
+	}
+}
+
+func TestBatchRoundTrip(t *testing.T) {
+	req := &BatchRequest{Group: "scores", Keys: []string{"Tom", "", "Jack"}}
+	gotReq := &BatchRequest{}
+	if err := gotReq.Unmarshal(req.Marshal()); err != nil || !reflect.DeepEqual(req, gotReq) {
+		t.Fatalf("request round trip got %+v, err %v", gotReq, err)
+	}
+
+	res := &BatchResponse{Responses: []Response{
+		{Value: []byte("630"), TTL: time.Minute},
+		{Code: CodeKeyNotFound, Error: "Jack not exist", TTL: time.Second},
+	}}
+	gotRes := &BatchResponse{}
+	if err := gotRes.Unmarshal(res.Marshal()); err != nil || !reflect.DeepEqual(res, gotRes) {
+		t.Fatalf("response round trip got %+v, err %v", gotRes, err)
+	}
+
+	data := res.Marshal()
+	if err := gotRes.Unmarshal(data[:len(data)-2]); !errors.Is(err, ErrTruncated) {
+		t.Fatalf("truncated batch should fail, got %v", err)
+	}
+}
@@ -0,0 +1,63 @@
+package cache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// minTransferCompressSize 节点间传输的响应体超过该大小且对方接受 gzip 时才压缩
+const minTransferCompressSize = 1 << 10
+
+// gzipWriters 复用 gzip.Writer，避免每次压缩都分配压缩表
+var gzipWriters = sync.Pool{
+	New: func() interface{} { return gzip.NewWriter(nil) },
+}
+
+// gzipBytes 返回 b 经 gzip 压缩后的数据
+func gzipBytes(b []byte) []byte {
+	var buf bytes.Buffer
+	zw := gzipWriters.Get().(*gzip.Writer)
+	zw.Reset(&buf)
+	zw.Write(b)
+	zw.Close()
+	gzipWriters.Put(zw)
+	return buf.Bytes()
+}
+
+// gunzipBytes 解压 gzipBytes 生成的数据
+func gunzipBytes(b []byte) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return io.ReadAll(zr)
+}
+
+// compress 按 compressThreshold 压缩缓存项
+func (g *Group) compress(value ByteView) ByteView {
+	if g.compressThreshold == 0 || value.compressed || len(value.b) < g.compressThreshold {
+		return value
+	}
+	if z := gzipBytes(value.b); len(z) < len(value.b) {
+		value.b, value.compressed, value.n = z, true, len(value.b)
+	}
+	return value
+}
+
+// acceptsGzip 判断请求方是否接受 gzip 编码的响应
+func acceptsGzip(r *http.Request) bool {
+	for _, v := range r.Header.Values("Accept-Encoding") {
+		for _, enc := range strings.Split(v, ",") {
+			enc, q, _ := strings.Cut(strings.TrimSpace(enc), ";")
+			if strings.EqualFold(strings.TrimSpace(enc), "gzip") && strings.TrimSpace(q) != "q=0" {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,137 @@
+package cache
+
+import (
+	"bytes"
+	pb "cache/cachepb"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCompression(t *testing.T) {
+	large := strings.Repeat(`{"name":"jw","score":630}`, 100)
+	g := newTestGroup(t, "compress", 2<<20, GetterFunc(
+		func(key string) ([]byte, error) {
+			if key == "large" {
+				return []byte(large), nil
+			}
+			return []byte(key), nil
+		}), WithCompression(256))
+
+	for _, key := range []string{"large", "small"} {
+		if _, err := g.Get(key); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 缓存容量按压缩后的大小计算，Len 仍返回原始长度
+	v, ok := g.mainCache.get("large")
+	if !ok || !v.compressed || (entry{v}).Len() >= len(large) || v.Len() != len(large) {
+		t.Fatalf("large value should be stored compressed, stored %d bytes, Len %d", (entry{v}).Len(), v.Len())
+	}
+	if s := g.CacheStats(MainCache); s.Bytes >= int64(len(large)) {
+		t.Fatalf("cache should be charged the compressed size, got %d bytes", s.Bytes)
+	}
+	if v.String() != large || string(v.ByteSlice()) != large {
+		t.Fatalf("compressed value should read back the original data")
+	}
+	if v, _ := g.mainCache.get("small"); v.compressed {
+		t.Fatalf("values below the threshold should not be compressed")
+	}
+	// 调用方拿到的值与缓存中的值一致
+	if v, _ := g.Get("large"); v.String() != large {
+		t.Fatalf("Get from cache = %d bytes, want %d", len(v.String()), len(large))
+	}
+}
+
+func TestCompressedTransfer(t *testing.T) {
+	large := strings.Repeat("boyue", 1000)
+	newTestGroup(t, "compress-peer", 2<<20, GetterFunc(
+		func(key string) ([]byte, error) {
+			return []byte(large), nil
+		}))
+	srv := httptest.NewServer(NewHTTPPool("compress-peer"))
+	defer srv.Close()
+
+	req, _ := http.NewRequest("GET", srv.URL+defaultBasePath+"compress-peer/jw", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	res, err := http.DefaultTransport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.Header.Get("Content-Encoding") != "gzip" {
+		t.Fatalf("large response should be gzip encoded when accepted")
+	}
+
+	req.Header.Del("Accept-Encoding")
+	if res, err = http.DefaultTransport.RoundTrip(req); err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.Header.Get("Content-Encoding") != "" {
+		t.Fatalf("response should not be encoded without Accept-Encoding")
+	}
+
+	h := &httpGetter{baseURL: srv.URL + defaultBasePath}
+	out := &pb.Response{}
+	if err := h.Get(context.Background(), &pb.Request{Group: "compress-peer", Key: "jw"}, out); err != nil || string(out.Value) != large {
+		t.Fatalf("Get got %d bytes, err %v", len(out.Value), err)
+	}
+	batch := &pb.BatchResponse{}
+	if err := h.GetMulti(context.Background(), &pb.BatchRequest{Group: "compress-peer", Keys: []string{"a", "b"}}, batch); err != nil ||
+		len(batch.Responses) != 2 || string(batch.Responses[1].Value) != large {
+		t.Fatalf("GetMulti got %d responses, err %v", len(batch.Responses), err)
+	}
+}
+
+// TestStoredCompressedTransfer 压缩存储的值原样发送给接受 gzip 的请求方，不再解压后重新压缩
+func TestStoredCompressedTransfer(t *testing.T) {
+	large := strings.Repeat(`{"name":"jw","score":630}`, 100)
+	g := newTestGroup(t, "compress-stored", 2<<20, GetterFunc(
+		func(key string) ([]byte, error) {
+			return []byte(large), nil
+		}), WithTTL(time.Minute), WithCompression(256))
+	g.Get("jw")
+	stored, ok := g.mainCache.get("jw")
+	if !ok || !stored.compressed {
+		t.Fatalf("value should be stored compressed")
+	}
+	srv := httptest.NewServer(NewHTTPPool("compress-stored"))
+	defer srv.Close()
+
+	req, _ := http.NewRequest("GET", srv.URL+defaultBasePath+"compress-stored/jw", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	res, err := http.DefaultTransport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+	if res.Header.Get("Content-Encoding") != "gzip" || !bytes.Contains(body, stored.b) {
+		t.Fatalf("response should carry the stored compressed bytes")
+	}
+
+	h := &httpGetter{baseURL: srv.URL + defaultBasePath}
+	out := &pb.Response{}
+	if err := h.Get(context.Background(), &pb.Request{Group: "compress-stored", Key: "jw"}, out); err != nil ||
+		string(out.Value) != large || out.TTL <= 0 || out.TTL > time.Minute {
+		t.Fatalf("Get got %d bytes, ttl %v, err %v", len(out.Value), out.TTL, err)
+	}
+
+	// 不接受 gzip 的请求方得到解压后的值
+	req.Header.Del("Accept-Encoding")
+	if res, err = http.DefaultTransport.RoundTrip(req); err != nil {
+		t.Fatal(err)
+	}
+	body, _ = io.ReadAll(res.Body)
+	res.Body.Close()
+	out = &pb.Response{}
+	if err := out.Unmarshal(body); err != nil || string(out.Value) != large {
+		t.Fatalf("plain response got %d bytes, err %v", len(out.Value), err)
+	}
+}
@@ -2,6 +2,7 @@ package consistenthash
 
 import (
 	"hash/crc32"
+	"math"
 	"sort"
 	"strconv"
 )
@@ -12,11 +13,22 @@ type Hash func(data []byte) uint32
 // - replicas 表示虚拟节点数量，用于平衡数据分布
 // - keys 保存所有虚拟节点的哈希值（升序）
 // - hashMap 将虚拟节点哈希值映射回真实节点名称
+// - weights 记录每个真实节点的权重，权重为 w 的节点拥有 w*replicas 个虚拟节点
+// - loadFactor 不为 0 时启用有界负载模式，loads 记录每个真实节点的当前负载
+//
+// Map 不是并发安全的，由调用方加锁保护
 type Map struct {
 	hash     Hash
 	replicas int
 	keys     []int
 	hashMap  map[int]string
+	weights  map[string]int
+	// totalWeight 所有真实节点的权重之和
+	totalWeight int
+
+	loadFactor float64
+	loads      map[string]int64
+	totalLoad  int64
 }
 
 // New 根据指定的虚拟节点数量和哈希函数创建 Map，若未指定哈希函数则默认使用 crc32.
@@ -25,6 +37,8 @@ func New(replicas int, fn Hash) *Map {
 		replicas: replicas,
 		hash:     fn,
 		hashMap:  make(map[int]string),
+		weights:  make(map[string]int),
+		loads:    make(map[string]int64),
 	}
 
 	if m.hash == nil {
@@ -37,18 +51,41 @@ func New(replicas int, fn Hash) *Map {
 // - 为每个真实节点生成 replicas 个虚拟节点，命名为 i+key（i 是下标）
 // - 对虚拟节点命名求哈希，插入有序切片 keys，并记录映射关系
 // - 最后对 keys 排序，确保后续二分查找可用
+// 已存在的节点会被忽略
 func (m *Map) Add(keys ...string) {
 	for _, key := range keys {
-		for i := 0; i < m.replicas; i++ {
-			//通过添加编号的方式区分不同虚拟节点。
-			hash := int(m.hash([]byte(strconv.Itoa(i) + key)))
-			m.keys = append(m.keys, hash)
-			m.hashMap[hash] = key
+		if _, ok := m.weights[key]; !ok {
+			m.add(key, 1)
 		}
 	}
 	sort.Ints(m.keys)
 }
 
+// AddWithWeight 按权重将真实节点加入哈希环，权重为 weight 的节点拥有 weight*replicas 个虚拟节点，
+// 分到的 key 也大致与权重成正比。节点已存在时按新权重重新加入
+func (m *Map) AddWithWeight(key string, weight int) {
+	if weight <= 0 {
+		return
+	}
+	if _, ok := m.weights[key]; ok {
+		m.Remove(key)
+	}
+	m.add(key, weight)
+	sort.Ints(m.keys)
+}
+
+// add 生成虚拟节点，调用方负责对 keys 排序
+func (m *Map) add(key string, weight int) {
+	m.weights[key] = weight
+	m.totalWeight += weight
+	for i := 0; i < m.replicas*weight; i++ {
+		//通过添加编号的方式区分不同虚拟节点。
+		hash := int(m.hash([]byte(strconv.Itoa(i) + key)))
+		m.keys = append(m.keys, hash)
+		m.hashMap[hash] = key
+	}
+}
+
 // Get 查找给定 key 对应的真实节点：
 // - 先计算 key 的哈希值
 // - 利用二分查找找到第一个 >= hash 的虚拟节点索引
@@ -66,8 +103,109 @@ func (m *Map) Get(key string) string {
 		return m.keys[i] >= hash
 	})
 
+	if m.loadFactor > 0 {
+		// 有界负载模式：顺时针跳过负载已满的节点
+		for i := 0; i < len(m.keys); i++ {
+			node := m.hashMap[m.keys[(idx+i)%len(m.keys)]]
+			if m.loads[node]+1 <= m.maxLoad(node) {
+				return node
+			}
+		}
+	}
+
 	// idx == len(m.keys) 说明 hash 大于所有虚拟节点哈希值，取环状的第一个节点
 	return m.hashMap[m.keys[idx%len(m.keys)]]
 }
 
-//
+// SetLoadFactor 启用有界负载的一致性哈希（consistent hashing with bounded loads）：
+// 每个节点的负载上限为 ceil(c * 平均负载 * 节点权重)，Get 会跳过负载已满的节点。
+// c 必须大于 1，c <= 0 表示关闭该模式
+func (m *Map) SetLoadFactor(c float64) {
+	if c > 0 && c <= 1 {
+		panic("consistenthash: load factor must be greater than 1")
+	}
+	m.loadFactor = max(c, 0)
+}
+
+// Inc 为节点增加一个单位负载，通常在把请求发往 Get 返回的节点时调用
+func (m *Map) Inc(node string) {
+	if _, ok := m.weights[node]; !ok {
+		return
+	}
+	m.loads[node]++
+	m.totalLoad++
+}
+
+// Done 为节点减少一个单位负载，与 Inc 成对调用
+func (m *Map) Done(node string) {
+	if m.loads[node] <= 0 {
+		return
+	}
+	m.loads[node]--
+	m.totalLoad--
+}
+
+// Load 返回节点当前的负载
+func (m *Map) Load(node string) int64 {
+	return m.loads[node]
+}
+
+// maxLoad 计算节点在放入下一个负载后允许的负载上限
+func (m *Map) maxLoad(node string) int64 {
+	avg := float64(m.totalLoad+1) / float64(m.totalWeight)
+	return int64(math.Ceil(avg * m.loadFactor * float64(m.weights[node])))
+}
+
+// GetN 沿哈希环顺时针查找 key 之后的 n 个不同真实节点，第一个即为 Get 的结果
+// 当某个节点不可用时，可以依次尝试后面的节点
+func (m *Map) GetN(key string, n int) []string {
+	if len(m.keys) == 0 || n <= 0 {
+		return nil
+	}
+
+	hash := int(m.hash([]byte(key)))
+	idx := sort.Search(len(m.keys), func(i int) bool {
+		return m.keys[i] >= hash
+	})
+
+	nodes := make([]string, 0, n)
+	seen := make(map[string]bool, n)
+	for i := 0; i < len(m.keys) && len(nodes) < n; i++ {
+		node := m.hashMap[m.keys[(idx+i)%len(m.keys)]]
+		if !seen[node] {
+			seen[node] = true
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes
+}
+
+// Remove 将真实节点及其所有虚拟节点从哈希环中移除
+// 其余节点的虚拟节点保持不变，只有原本落在被移除节点上的 key 会迁移
+func (m *Map) Remove(keys ...string) {
+	for _, key := range keys {
+		weight, ok := m.weights[key]
+		if !ok {
+			continue
+		}
+		delete(m.weights, key)
+		m.totalWeight -= weight
+		m.totalLoad -= m.loads[key]
+		delete(m.loads, key)
+		for i := 0; i < m.replicas*weight; i++ {
+			hash := int(m.hash([]byte(strconv.Itoa(i) + key)))
+			// 哈希冲突时虚拟节点可能已属于其他真实节点，不能误删
+			if m.hashMap[hash] == key {
+				delete(m.hashMap, hash)
+			}
+		}
+	}
+
+	keep := m.keys[:0]
+	for _, hash := range m.keys {
+		if _, ok := m.hashMap[hash]; ok {
+			keep = append(keep, hash)
+		}
+	}
+	m.keys = keep
+}
@@ -1,6 +1,7 @@
 package consistenthash
 
 import (
+	"reflect"
 	"strconv"
 	"testing"
 )
@@ -32,8 +33,7 @@ func TestHashing(t *testing.T) {
 	hash.Add("8")
 
 	// 由于虚拟节点的插入，27 现在应该映射到新节点 "8"
-	// testCases["27"] = "8"
-	testCases["27"] = "4"
+	testCases["27"] = "8"
 
 	for k, v := range testCases {
 		if hash.Get(k) != v {
@@ -41,3 +41,149 @@ func TestHashing(t *testing.T) {
 		}
 	}
 }
+
+func TestRemove(t *testing.T) {
+	hash := New(3, func(key []byte) uint32 {
+		i, _ := strconv.Atoi(string(key))
+		return uint32(i)
+	})
+
+	// 2 4 6 8 12 14 16 18 22 24 26 28
+	hash.Add("6", "4", "2", "8")
+	hash.Remove("8")
+
+	// 移除 8 后恢复为只有 2 4 6 时的映射
+	testCases := map[string]string{
+		"2":  "2",
+		"11": "2",
+		"23": "4",
+		"27": "2",
+	}
+	for k, v := range testCases {
+		if hash.Get(k) != v {
+			t.Errorf("Asking for %s, should have yielded %s", k, v)
+		}
+	}
+
+	hash.Remove("6", "4", "2")
+	if hash.Get("2") != "" {
+		t.Errorf("empty ring should yield nothing")
+	}
+}
+
+func TestGetN(t *testing.T) {
+	hash := New(3, func(key []byte) uint32 {
+		i, _ := strconv.Atoi(string(key))
+		return uint32(i)
+	})
+
+	// 2 4 6 12 14 16 22 24 26
+	hash.Add("6", "4", "2")
+
+	testCases := map[string][]string{
+		"11": {"2", "4", "6"},
+		"23": {"4", "6", "2"},
+		"27": {"2", "4", "6"},
+	}
+	for k, v := range testCases {
+		if got := hash.GetN(k, 3); !reflect.DeepEqual(got, v) {
+			t.Errorf("Asking for %s, should have yielded %v, got %v", k, v, got)
+		}
+	}
+
+	if got := hash.GetN("23", 10); len(got) != 3 {
+		t.Errorf("GetN should return at most all real nodes, got %v", got)
+	}
+}
+
+// distribution 统计 n 个 key 在各节点上的分布
+func distribution(m *Map, n int, inc bool) map[string]int {
+	counts := make(map[string]int)
+	for i := 0; i < n; i++ {
+		node := m.Get("key" + strconv.Itoa(i))
+		counts[node]++
+		if inc {
+			m.Inc(node)
+		}
+	}
+	return counts
+}
+
+// skew 返回 max(实际份额/期望份额)，1 表示完全均衡
+func skew(counts map[string]int, weights map[string]int) float64 {
+	total, totalWeight := 0, 0
+	for node, c := range counts {
+		total += c
+		totalWeight += weights[node]
+	}
+	worst := 0.0
+	for node, c := range counts {
+		expect := float64(total) * float64(weights[node]) / float64(totalWeight)
+		worst = max(worst, float64(c)/expect)
+	}
+	return worst
+}
+
+func TestWeightedDistribution(t *testing.T) {
+	weights := map[string]int{"small": 1, "medium": 2, "large": 4}
+	m := New(50, nil)
+	for node, w := range weights {
+		m.AddWithWeight(node, w)
+	}
+
+	counts := distribution(m, 70000, false)
+	s := skew(counts, weights)
+	t.Logf("weighted distribution %v, skew %.3f", counts, s)
+	if s > 1.3 {
+		t.Fatalf("weighted distribution too skewed: %v (skew %.3f)", counts, s)
+	}
+	if counts["large"] < 3*counts["small"] {
+		t.Fatalf("large node should get far more keys than small node: %v", counts)
+	}
+
+	// 改变权重后重新加入，不会残留旧的虚拟节点
+	m.AddWithWeight("large", 1)
+	if len(m.keys) != 50*(1+2+1) {
+		t.Fatalf("re-weighted ring should have %d virtual nodes, got %d", 50*4, len(m.keys))
+	}
+}
+
+func TestBoundedLoads(t *testing.T) {
+	nodes := map[string]int{"a": 1, "b": 1, "c": 1, "d": 1}
+	unbounded := New(3, nil)
+	bounded := New(3, nil)
+	for node := range nodes {
+		unbounded.Add(node)
+		bounded.Add(node)
+	}
+	bounded.SetLoadFactor(1.25)
+
+	const n = 10000
+	before := skew(distribution(unbounded, n, false), nodes)
+	counts := distribution(bounded, n, true)
+	after := skew(counts, nodes)
+	t.Logf("skew without bound %.3f, with bound %.3f", before, after)
+
+	limit := int64(1.25*n/4) + 1
+	for node, c := range counts {
+		if int64(c) > limit || bounded.Load(node) != int64(c) {
+			t.Fatalf("node %s load %d exceeds bound %d", node, c, limit)
+		}
+	}
+	if after > before {
+		t.Fatalf("bounded loads should not increase skew: %.3f > %.3f", after, before)
+	}
+
+	// 负载全部释放后，映射与普通一致性哈希相同
+	for node, c := range counts {
+		for i := 0; i < c; i++ {
+			bounded.Done(node)
+		}
+	}
+	for i := 0; i < 100; i++ {
+		key := "key" + strconv.Itoa(i)
+		if bounded.Get(key) != unbounded.Get(key) {
+			t.Fatalf("released ring should map %s like the unbounded ring", key)
+		}
+	}
+}
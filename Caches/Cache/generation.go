@@ -0,0 +1,155 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultGenerationPath 接收其他节点广播的代数
+	defaultGenerationPath = "/_generation"
+	// broadcastRetries 广播失败后在后台重试的次数，间隔从 minBackoff 开始翻倍
+	broadcastRetries = 5
+)
+
+// GenerationBroadcaster 可选接口：PeerPicker 实现后，Group.Invalidate 会把新的代数通知所有其他节点
+type GenerationBroadcaster interface {
+	BroadcastGeneration(ctx context.Context, group string, gen uint64) error
+}
+
+// Generation 返回 Group 当前的代数
+func (g *Group) Generation() uint64 {
+	return g.generation.Load()
+}
+
+// Invalidate 使 Group 中已缓存的所有 key 一次性失效：代数加一，之前加载的缓存项都视为未命中，
+// 在重新加载时被覆盖或随容量淘汰，不需要遍历缓存。
+// 注册的节点实现了 GenerationBroadcaster 时，新的代数会广播给所有节点，返回广播中遇到的错误，
+// 失败时在后台重试。节点间的请求与响应也都携带代数，错过广播的节点在下一次通信时同样会追上
+func (g *Group) Invalidate(ctx context.Context) (uint64, error) {
+	gen := g.generation.Add(1)
+	log.Printf("[Cache] group %s invalidated, generation %d", g.name, gen)
+	b, ok := g.peer.(GenerationBroadcaster)
+	if !ok {
+		return gen, nil
+	}
+	err := b.BroadcastGeneration(ctx, g.name, gen)
+	if err != nil {
+		go g.retryBroadcast(b, gen)
+	}
+	return gen, err
+}
+
+// retryBroadcast 重新广播代数 gen，直到成功、代数被更新的 Invalidate 取代、Group 注销或重试次数用完
+// 推进代数是幂等的，已经收到的节点再收到一次也没有影响
+func (g *Group) retryBroadcast(b GenerationBroadcaster, gen uint64) {
+	backoff := minBackoff
+	for i := 0; i < broadcastRetries; i++ {
+		select {
+		case <-time.After(backoff):
+		case <-g.ctx.Done():
+			return
+		}
+		backoff = min(backoff*2, maxBackoff)
+		if g.Generation() != gen {
+			return
+		}
+		ctx, cancel := context.WithTimeout(g.ctx, g.loadTimeout)
+		err := b.BroadcastGeneration(ctx, g.name, gen)
+		cancel()
+		if err == nil {
+			return
+		}
+		log.Printf("[Cache] broadcast generation %d of group %s: %v", gen, g.name, err)
+	}
+}
+
+// advanceGeneration 将代数推进到 gen，代数只增不减，各节点收到广播后取较大值
+func (g *Group) advanceGeneration(gen uint64) {
+	for {
+		cur := g.generation.Load()
+		if gen <= cur || g.generation.CompareAndSwap(cur, gen) {
+			return
+		}
+	}
+}
+
+// BroadcastGeneration 将 group 的代数并发通知除自身外的所有节点，返回所有失败节点的错误
+func (p *HTTPPool) BroadcastGeneration(ctx context.Context, group string, gen uint64) error {
+	p.mu.Lock()
+	targets := make(map[string]*http.Client, len(p.httpGetters))
+	for peer, getter := range p.httpGetters {
+		if peer != p.self {
+			targets[peer] = getter.client
+		}
+	}
+	p.mu.Unlock()
+
+	query := url.Values{"group": {group}, "gen": {strconv.FormatUint(gen, 10)}}.Encode()
+	var mu sync.Mutex
+	var errs []error
+	var wg sync.WaitGroup
+	for peer, client := range targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := postGeneration(ctx, client, peer+defaultGenerationPath+"?"+query); err != nil {
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("%s: %w", peer, err))
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+func postGeneration(ctx context.Context, client *http.Client, url string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, nil)
+	if err != nil {
+		return err
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("server returned: %v", res.Status)
+	}
+	return nil
+}
+
+// serveGeneration 处理 POST /_generation?group=<group>&gen=<gen>，将本节点上 group 的代数推进到 gen
+func (p *HTTPPool) serveGeneration(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	name := r.URL.Query().Get("group")
+	gen, err := strconv.ParseUint(r.URL.Query().Get("gen"), 10, 64)
+	if err != nil {
+		http.Error(w, "bad generation", http.StatusBadRequest)
+		return
+	}
+	g := GetGroup(name)
+	if g == nil {
+		http.Error(w, "no such group: "+name, http.StatusBadRequest)
+		return
+	}
+	g.advanceGeneration(gen)
+	p.Log("group %s generation %d", name, gen)
+
+	w.Header().Set("Content-Type", "text/plain")
+	fmt.Fprintln(w, g.Generation())
+}
@@ -0,0 +1,119 @@
+package cache
+
+import (
+	pb "cache/cachepb"
+	"context"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestInvalidate(t *testing.T) {
+	var loads atomic.Int32
+	g := newTestGroup(t, "invalidate", 2<<10, versionedGetter(&loads))
+
+	g.Get("jw")
+	if v, _ := g.Get("jw"); v.String() != "v1" {
+		t.Fatalf("get = %q, want v1", v)
+	}
+	if gen, err := g.Invalidate(context.Background()); err != nil || gen != 1 {
+		t.Fatalf("Invalidate = %d, %v", gen, err)
+	}
+	if _, ok := g.peek("jw"); ok {
+		t.Fatalf("invalidated key should not be visible")
+	}
+	if v, _ := g.Get("jw"); v.String() != "v2" {
+		t.Fatalf("get after Invalidate = %q, want v2", v)
+	}
+	if v, _ := g.Get("jw"); v.String() != "v2" {
+		t.Fatalf("reloaded value should be cached, got %q", v)
+	}
+
+	// Invalidate 之前开始的加载不写入缓存
+	g.populateCache("Sam", ByteView{b: []byte("old"), ver: version{gen: g.Generation() - 1}}, g.mainCache)
+	if _, ok := g.mainCache.get("Sam"); ok {
+		t.Fatalf("value loaded in an older generation should not be cached")
+	}
+}
+
+func TestBroadcastGeneration(t *testing.T) {
+	g := newTestGroup(t, "broadcast", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			return []byte(key), nil
+		}))
+	srv := httptest.NewServer(NewHTTPPool("peer"))
+	defer srv.Close()
+
+	pool := NewHTTPPool("http://self")
+	pool.Set("http://self", srv.URL)
+	g.RegisterPeers(pool)
+
+	// 测试中两个节点共享同一进程的 Group，广播的代数与本地代数相同
+	if gen, err := g.Invalidate(context.Background()); err != nil || gen != 1 || g.Generation() != 1 {
+		t.Fatalf("Invalidate = %d, %v", gen, err)
+	}
+	if err := pool.BroadcastGeneration(context.Background(), "broadcast", 5); err != nil || g.Generation() != 5 {
+		t.Fatalf("generation = %d, err %v, want 5", g.Generation(), err)
+	}
+	// 代数只增不减
+	if err := pool.BroadcastGeneration(context.Background(), "broadcast", 3); err != nil || g.Generation() != 5 {
+		t.Fatalf("generation = %d, err %v, want 5", g.Generation(), err)
+	}
+	if err := pool.BroadcastGeneration(context.Background(), "unknown", 1); err == nil {
+		t.Fatalf("broadcasting an unknown group should fail")
+	}
+}
+
+// genPeer 返回固定代数的节点
+type genPeer struct {
+	gen uint64
+}
+
+func (p *genPeer) PickPeer(key string) (PeerGetter, bool) {
+	return p, true
+}
+
+func (p *genPeer) Get(_ context.Context, in *pb.Request, out *pb.Response) error {
+	*out = pb.Response{Value: []byte(in.Key), Gen: p.gen}
+	return nil
+}
+
+func (p *genPeer) Remove(context.Context, *pb.Request) error { return nil }
+
+func (p *genPeer) Set(context.Context, *pb.Request) error { return nil }
+
+// TestGenerationConverge 错过广播的节点在与其他节点通信时追上较大的代数
+func TestGenerationConverge(t *testing.T) {
+	g := newTestGroup(t, "converge", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			return []byte(key), nil
+		}))
+	srv := httptest.NewServer(NewHTTPPool("peer"))
+	defer srv.Close()
+	h := &httpGetter{baseURL: srv.URL + defaultBasePath}
+
+	// 请求携带请求方的代数，响应携带推进后的代数
+	res := &pb.Response{}
+	if err := h.Get(context.Background(), &pb.Request{Group: "converge", Key: "jw", Gen: 4}, res); err != nil {
+		t.Fatal(err)
+	}
+	if g.Generation() != 4 || res.Gen != 4 {
+		t.Fatalf("generation = %d, response gen %d, want 4", g.Generation(), res.Gen)
+	}
+	batch := &pb.BatchResponse{}
+	if err := h.GetMulti(context.Background(), &pb.BatchRequest{Group: "converge", Keys: []string{"jw"}, Gen: 6}, batch); err != nil {
+		t.Fatal(err)
+	}
+	if g.Generation() != 6 || batch.Gen != 6 {
+		t.Fatalf("generation = %d, response gen %d, want 6", g.Generation(), batch.Gen)
+	}
+
+	// 响应中较大的代数同样推进本地代数，加载期间代数变化的值不写入缓存
+	g.RegisterPeers(&genPeer{gen: 9})
+	if v, err := g.Get("Sam"); err != nil || v.String() != "Sam" || g.Generation() != 9 {
+		t.Fatalf("Get = %q, %v, generation %d, want 9", v, err, g.Generation())
+	}
+	if _, ok := g.hotCache.peek("Sam"); ok {
+		t.Fatalf("value loaded before the generation advanced should not be cached")
+	}
+}
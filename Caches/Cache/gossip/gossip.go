@@ -0,0 +1,278 @@
+// Package gossip 实现一个轻量的基于心跳的成员发现协议
+//
+// 每个节点周期性地递增自己的心跳，并把自己已知的成员列表推送给随机几个节点，
+// 对方合并后返回它的成员列表（push-pull）。某个成员的心跳长时间没有增长时，
+// 认为该成员已经失效。
+package gossip
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"math/rand"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Path 节点之间交换成员列表的 HTTP 路径
+const Path = "/_gossip"
+
+const (
+	defaultInterval    = time.Second
+	defaultFailTimeout = 5 * time.Second
+	defaultFanout      = 2
+)
+
+// Member 在节点之间传播的成员信息
+type Member struct {
+	Addr      string `json:"addr"`
+	Heartbeat int64  `json:"heartbeat"`
+}
+
+// member 本地维护的成员状态
+type member struct {
+	heartbeat int64
+	updated   time.Time // 最近一次心跳增长的时间
+	alive     bool
+}
+
+// Node 表示参与 gossip 的一个节点
+type Node struct {
+	self  string
+	seeds []string
+
+	// Interval 两轮 gossip 之间的间隔
+	Interval time.Duration
+	// FailTimeout 心跳超过该时间未增长的成员视为失效
+	FailTimeout time.Duration
+	// Fanout 每轮推送的目标节点数
+	Fanout int
+	// Client 发送 gossip 请求使用的 HTTP 客户端
+	Client *http.Client
+
+	onJoin  func(addr string)
+	onLeave func(addr string)
+
+	mu      sync.Mutex
+	members map[string]*member
+	stop    chan struct{}
+}
+
+// New 创建节点，self 与 seeds 均为形如 http://host:port 的地址
+// 发现新成员时调用 onJoin，成员失效时调用 onLeave，两者均可为 nil
+func New(self string, seeds []string, onJoin, onLeave func(addr string)) *Node {
+	n := &Node{
+		self:        self,
+		seeds:       seeds,
+		Interval:    defaultInterval,
+		FailTimeout: defaultFailTimeout,
+		Fanout:      defaultFanout,
+		Client:      &http.Client{Timeout: time.Second},
+		onJoin:      onJoin,
+		onLeave:     onLeave,
+		members:     make(map[string]*member),
+	}
+	// 心跳从当前时间开始计数，重启后的节点心跳一定大于旧值，能被其他节点重新接纳
+	n.members[self] = &member{heartbeat: time.Now().UnixNano(), updated: time.Now(), alive: true}
+	return n
+}
+
+// Start 在后台开始周期性 gossip
+func (n *Node) Start() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.stop != nil {
+		return
+	}
+	n.stop = make(chan struct{})
+	go n.loop(n.stop)
+}
+
+// Stop 停止后台 gossip
+func (n *Node) Stop() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.stop != nil {
+		close(n.stop)
+		n.stop = nil
+	}
+}
+
+// Members 返回当前存活的成员地址（含自身，升序）
+func (n *Node) Members() []string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	addrs := make([]string, 0, len(n.members))
+	for addr, m := range n.members {
+		if m.alive {
+			addrs = append(addrs, addr)
+		}
+	}
+	sort.Strings(addrs)
+	return addrs
+}
+
+func (n *Node) loop(stop chan struct{}) {
+	ticker := time.NewTicker(n.Interval)
+	defer ticker.Stop()
+
+	n.round()
+	for {
+		select {
+		case <-ticker.C:
+			n.round()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// round 执行一轮 gossip：递增心跳、推送成员列表、检测失效成员
+func (n *Node) round() {
+	n.mu.Lock()
+	n.members[n.self].heartbeat++
+	n.members[n.self].updated = time.Now()
+	targets := n.targets()
+	n.mu.Unlock()
+
+	for _, target := range targets {
+		n.push(target)
+	}
+	n.detectFailures()
+}
+
+// targets 从存活成员和种子节点中随机选出 Fanout 个目标，调用方需持有 mu
+func (n *Node) targets() []string {
+	candidates := make(map[string]struct{})
+	for addr, m := range n.members {
+		if m.alive {
+			candidates[addr] = struct{}{}
+		}
+	}
+	for _, seed := range n.seeds {
+		candidates[seed] = struct{}{}
+	}
+	delete(candidates, n.self)
+
+	addrs := make([]string, 0, len(candidates))
+	for addr := range candidates {
+		addrs = append(addrs, addr)
+	}
+	rand.Shuffle(len(addrs), func(i, j int) { addrs[i], addrs[j] = addrs[j], addrs[i] })
+	if len(addrs) > n.Fanout {
+		addrs = addrs[:n.Fanout]
+	}
+	return addrs
+}
+
+// snapshot 返回存活成员列表，用于发送给其他节点
+func (n *Node) snapshot() []Member {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	list := make([]Member, 0, len(n.members))
+	for addr, m := range n.members {
+		if m.alive {
+			list = append(list, Member{Addr: addr, Heartbeat: m.heartbeat})
+		}
+	}
+	return list
+}
+
+// push 将本地成员列表推送给 target，并合并对方返回的列表
+func (n *Node) push(target string) {
+	body, err := json.Marshal(n.snapshot())
+	if err != nil {
+		return
+	}
+	res, err := n.Client.Post(target+Path, "application/json", bytes.NewReader(body))
+	if err != nil {
+		// 推送失败不做处理，失效检测只依赖心跳
+		return
+	}
+	defer res.Body.Close()
+
+	var list []Member
+	if err := json.NewDecoder(res.Body).Decode(&list); err != nil {
+		return
+	}
+	n.merge(list)
+}
+
+// merge 合并收到的成员列表，心跳更大的信息覆盖本地信息
+func (n *Node) merge(list []Member) {
+	var joined []string
+
+	n.mu.Lock()
+	now := time.Now()
+	for _, in := range list {
+		if in.Addr == n.self {
+			continue
+		}
+		m, ok := n.members[in.Addr]
+		if !ok {
+			m = &member{}
+			n.members[in.Addr] = m
+		}
+		if ok && in.Heartbeat <= m.heartbeat {
+			continue
+		}
+		m.heartbeat = in.Heartbeat
+		m.updated = now
+		if !m.alive {
+			m.alive = true
+			joined = append(joined, in.Addr)
+		}
+	}
+	n.mu.Unlock()
+
+	for _, addr := range joined {
+		log.Printf("[Gossip %s] member joined %s", n.self, addr)
+		if n.onJoin != nil {
+			n.onJoin(addr)
+		}
+	}
+}
+
+// detectFailures 将心跳超时的成员标记为失效
+// 失效成员仍保留心跳记录，只有心跳继续增长（如重启）才会被重新接纳
+func (n *Node) detectFailures() {
+	var left []string
+
+	n.mu.Lock()
+	now := time.Now()
+	for addr, m := range n.members {
+		if addr != n.self && m.alive && now.Sub(m.updated) > n.FailTimeout {
+			m.alive = false
+			left = append(left, addr)
+		}
+	}
+	n.mu.Unlock()
+
+	for _, addr := range left {
+		log.Printf("[Gossip %s] member left %s", n.self, addr)
+		if n.onLeave != nil {
+			n.onLeave(addr)
+		}
+	}
+}
+
+// ServeHTTP 处理其他节点推送的成员列表，返回本地成员列表
+func (n *Node) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var list []Member
+	if err := json.NewDecoder(r.Body).Decode(&list); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	n.merge(list)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(n.snapshot())
+}
@@ -0,0 +1,104 @@
+package gossip
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testNode 在本地启动一个 gossip 节点
+type testNode struct {
+	*Node
+	srv *httptest.Server
+
+	mu    sync.Mutex
+	peers map[string]bool // 通过回调维护的成员集合
+}
+
+func startNode(t *testing.T, seeds []string) *testNode {
+	tn := &testNode{peers: make(map[string]bool)}
+	var ready sync.WaitGroup
+	ready.Add(1)
+	tn.srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ready.Wait()
+		tn.ServeHTTP(w, r)
+	}))
+	tn.Node = New(tn.srv.URL, seeds, func(addr string) {
+		tn.mu.Lock()
+		defer tn.mu.Unlock()
+		tn.peers[addr] = true
+	}, func(addr string) {
+		tn.mu.Lock()
+		defer tn.mu.Unlock()
+		delete(tn.peers, addr)
+	})
+	ready.Done()
+	tn.Interval = 10 * time.Millisecond
+	tn.FailTimeout = 100 * time.Millisecond
+	tn.Start()
+	t.Cleanup(func() {
+		tn.Stop()
+		tn.srv.Close()
+	})
+	return tn
+}
+
+func (tn *testNode) callbackPeers() []string {
+	tn.mu.Lock()
+	defer tn.mu.Unlock()
+	addrs := make([]string, 0, len(tn.peers))
+	for addr := range tn.peers {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+	return addrs
+}
+
+// waitFor 在超时前等待 cond 成立
+func waitFor(t *testing.T, what string, cond func() bool) {
+	deadline := time.Now().Add(3 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestDiscoveryAndFailure(t *testing.T) {
+	seed := startNode(t, nil)
+	a := startNode(t, []string{seed.srv.URL})
+	b := startNode(t, []string{seed.srv.URL})
+
+	all := []string{seed.srv.URL, a.srv.URL, b.srv.URL}
+	sort.Strings(all)
+	for _, n := range []*testNode{seed, a, b} {
+		waitFor(t, "discovery", func() bool {
+			return reflect.DeepEqual(n.Members(), all)
+		})
+	}
+
+	// b 只配置了种子节点，a 是通过 gossip 发现的
+	others := []string{seed.srv.URL, a.srv.URL}
+	sort.Strings(others)
+	if got := b.callbackPeers(); !reflect.DeepEqual(got, others) {
+		t.Fatalf("onJoin peers of b = %v, want %v", got, others)
+	}
+
+	b.Stop()
+	b.srv.Close()
+	for _, n := range []*testNode{seed, a} {
+		waitFor(t, "failure detection", func() bool {
+			for _, peer := range n.callbackPeers() {
+				if peer == b.srv.URL {
+					return false
+				}
+			}
+			return len(n.Members()) == 2
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package cache
+
+import (
+	pb "cache/cachepb"
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	// failureThreshold 连续失败多少次后熔断
+	failureThreshold = 3
+	// minBackoff 第一次熔断的时长，之后每次翻倍
+	minBackoff = time.Second
+	// maxBackoff 熔断时长的上限
+	maxBackoff = time.Minute
+)
+
+// peerHealth 记录远程节点的健康状况，实现一个简单的熔断器：
+// 连续失败 failureThreshold 次后熔断一段时间，期间不再选择该节点；
+// 熔断到期后每个熔断周期只放行一次试探请求，成功则恢复，失败则以翻倍的时长再次熔断
+type peerHealth struct {
+	mu        sync.Mutex
+	failures  int
+	backoff   time.Duration
+	openUntil time.Time
+}
+
+// allow 判断当前是否可以向该节点发送请求
+func (h *peerHealth) allow() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.failures < failureThreshold {
+		return true
+	}
+	now := time.Now()
+	if now.Before(h.openUntil) {
+		return false
+	}
+	// 放行本次试探请求，在其结果返回前继续熔断
+	h.openUntil = now.Add(h.backoff)
+	return true
+}
+
+// healthy 判断节点当前是否未熔断，与 allow 不同，不会消耗熔断到期后的试探机会
+// 用于挑选副本：熔断过的节点只在作为主节点时接受试探
+func (h *peerHealth) healthy() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.failures < failureThreshold
+}
+
+// record 根据请求结果更新健康状况
+// 只有传输层错误才计为失败，远程节点返回的 *pb.Error 说明节点本身是正常的
+func (h *peerHealth) record(err error) {
+	if errors.Is(err, context.Canceled) {
+		// 调用方主动放弃，不能说明节点有问题；超时则可能是节点卡住，照常计为失败
+		return
+	}
+	var pe *pb.Error
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if err == nil || errors.As(err, &pe) {
+		h.failures = 0
+		h.backoff = 0
+		return
+	}
+
+	h.failures++
+	if h.failures >= failureThreshold {
+		if h.backoff == 0 {
+			h.backoff = minBackoff
+		} else {
+			h.backoff = min(h.backoff*2, maxBackoff)
+		}
+		h.openUntil = time.Now().Add(h.backoff)
+	}
+}
+
+// retryGetter 在主节点发生传输错误时，向哈希环上的下一个健康节点重试一次读请求
+// 写请求（Set/Remove）只发往主节点，避免数据写到非归属节点
+// GetMulti 按 primaryPeer 归并 key，同一主节点的 key 只发一次批量请求
+type retryGetter struct {
+	primary PeerGetter
+	replica PeerGetter
+}
+
+// primaryPeer 返回实际负责处理请求的节点：副本随 key 不同而不同，同一节点可能以
+// 单独的 PeerGetter 或不同副本的 retryGetter 出现，按节点归并时需要去掉副本
+func primaryPeer(peer PeerGetter) PeerGetter {
+	if r, ok := peer.(retryGetter); ok {
+		return r.primary
+	}
+	return peer
+}
+
+// retryable 判断错误是否值得在副本上重试：远程节点返回的错误和调用方放弃均不重试
+func retryable(ctx context.Context, err error) bool {
+	var pe *pb.Error
+	return err != nil && ctx.Err() == nil && !errors.As(err, &pe)
+}
+
+func (r retryGetter) Get(ctx context.Context, in *pb.Request, out *pb.Response) error {
+	err := r.primary.Get(ctx, in, out)
+	if retryable(ctx, err) {
+		*out = pb.Response{}
+		return r.replica.Get(ctx, in, out)
+	}
+	return err
+}
+
+// GetMulti 批量读取，主节点整体失败时在副本上重试一次
+func (r retryGetter) GetMulti(ctx context.Context, in *pb.BatchRequest, out *pb.BatchResponse) error {
+	err := getMulti(ctx, r.primary, in, out)
+	if retryable(ctx, err) {
+		*out = pb.BatchResponse{}
+		return getMulti(ctx, r.replica, in, out)
+	}
+	return err
+}
+
+func (r retryGetter) Remove(ctx context.Context, in *pb.Request) error {
+	return r.primary.Remove(ctx, in)
+}
+
+func (r retryGetter) Set(ctx context.Context, in *pb.Request) error {
+	return r.primary.Set(ctx, in)
+}
+
+var _ BatchPeerGetter = retryGetter{}
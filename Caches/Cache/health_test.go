@@ -0,0 +1,164 @@
+package cache
+
+import (
+	pb "cache/cachepb"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestPeerHealth(t *testing.T) {
+	h := &peerHealth{}
+	errDown := errors.New("connection refused")
+
+	for i := 0; i < failureThreshold; i++ {
+		if !h.allow() {
+			t.Fatalf("peer should be allowed before %d failures", failureThreshold)
+		}
+		h.record(errDown)
+	}
+	if h.allow() {
+		t.Fatalf("peer should be unhealthy after %d failures", failureThreshold)
+	}
+
+	// 熔断到期后只放行一次试探请求
+	h.openUntil = time.Now().Add(-time.Millisecond)
+	if !h.allow() || h.allow() {
+		t.Fatalf("only one probe should be allowed after backoff")
+	}
+	h.record(errDown)
+	if h.backoff != 2*minBackoff {
+		t.Fatalf("backoff should double, got %v", h.backoff)
+	}
+
+	// 远程节点返回的错误说明节点正常
+	h.record(&pb.Error{Code: pb.CodeNotFound})
+	if !h.allow() || h.failures != 0 {
+		t.Fatalf("peer should recover after a response")
+	}
+
+	// 调用方取消不计为失败
+	for i := 0; i < failureThreshold; i++ {
+		h.record(context.Canceled)
+	}
+	if !h.allow() {
+		t.Fatalf("canceled requests should not open the breaker")
+	}
+}
+
+func TestPickPeerSkipsUnhealthy(t *testing.T) {
+	pool := NewHTTPPool("http://localhost:8001")
+	pool.Set("http://localhost:8001", "http://localhost:8002")
+
+	getter := pool.httpGetters["http://localhost:8002"]
+	for i := 0; i < failureThreshold; i++ {
+		getter.health.record(errors.New("connection refused"))
+	}
+	for _, key := range []string{"jw", "boyue", "Sam"} {
+		if _, ok := pool.PickPeer(key); ok {
+			t.Fatalf("unhealthy peer should be skipped for %s", key)
+		}
+	}
+}
+
+// TestWriteToTrippedOwner 归属节点熔断时，写请求仍发往归属节点，失败后返回错误且不写入本地
+func TestWriteToTrippedOwner(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	owner := srv.URL
+	srv.Close()
+
+	self := "http://localhost:8001"
+	pool := NewHTTPPool(self)
+	pool.Set(self, owner)
+	getter := pool.httpGetters[owner]
+	for i := 0; i < failureThreshold; i++ {
+		getter.health.record(errors.New("connection refused"))
+	}
+
+	key := ""
+	for i := 0; key == "" && i < 1000; i++ {
+		if pool.peers.Get(strconv.Itoa(i)) == owner {
+			key = strconv.Itoa(i)
+		}
+	}
+	if key == "" {
+		t.Fatalf("no key owned by %s", owner)
+	}
+	if _, ok := pool.PickPeer(key); ok {
+		t.Fatalf("PickPeer should skip the tripped owner")
+	}
+	if peer, ok := pool.PickOwner(key); !ok || peer != getter {
+		t.Fatalf("PickOwner(%s) = %v, want %s", key, peer, owner)
+	}
+
+	g := newTestGroup(t, "tripped-owner", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			return []byte(key), nil
+		}), WithPeers(pool))
+
+	if err := g.Set(key, []byte("v")); err == nil {
+		t.Fatalf("Set should fail when the owner is unreachable")
+	}
+	if _, ok := g.mainCache.get(key); ok {
+		t.Fatalf("Set should not write to the local mainCache of a non-owner")
+	}
+	if err := g.Remove(key); err == nil {
+		t.Fatalf("Remove should fail when the owner is unreachable")
+	}
+}
+
+// TestPickPeerReplicaProbe 挑选副本时不消耗熔断节点的试探机会
+func TestPickPeerReplicaProbe(t *testing.T) {
+	self, a, b := "http://localhost:8001", "http://localhost:8002", "http://localhost:8003"
+	pool := NewHTTPPool(self)
+	pool.Set(self, a, b)
+
+	// 找一个归属 a、下一个节点为 b 的 key
+	key := ""
+	for i := 0; key == "" && i < 1000; i++ {
+		if order := pool.peers.GetN(strconv.Itoa(i), 3); order[0] == a && order[1] == b {
+			key = strconv.Itoa(i)
+		}
+	}
+	if key == "" {
+		t.Fatalf("no key owned by %s with replica %s", a, b)
+	}
+
+	// b 熔断到期，等待一次试探
+	replica := pool.httpGetters[b]
+	for i := 0; i < failureThreshold; i++ {
+		replica.health.record(errors.New("connection refused"))
+	}
+	replica.health.openUntil = time.Now().Add(-time.Millisecond)
+	openUntil := replica.health.openUntil
+
+	peer, ok := pool.PickPeer(key)
+	if !ok || peer != pool.httpGetters[a] {
+		t.Fatalf("PickPeer(%s) = %v, want primary %s without replica", key, peer, a)
+	}
+	if !replica.health.openUntil.Equal(openUntil) || !replica.health.allow() {
+		t.Fatalf("picking a replica should not consume its probe")
+	}
+}
+
+func TestRetryGetter(t *testing.T) {
+	down := &fakePeers{}
+	replica := &fakePeers{values: db}
+	r := &retryGetter{primary: down, replica: replica}
+
+	res := &pb.Response{}
+	if err := r.Get(context.Background(), &pb.Request{Key: "jw"}, res); err != nil || string(res.Value) != "114" {
+		t.Fatalf("should retry on replica, got %q err %v", res.Value, err)
+	}
+	if down.gets != 1 || replica.gets != 1 {
+		t.Fatalf("expect one attempt each, got %d %d", down.gets, replica.gets)
+	}
+
+	if err := r.Set(context.Background(), &pb.Request{Key: "jw", Value: []byte("1")}); err != nil || replica.set != nil {
+		t.Fatalf("writes should only go to primary")
+	}
+}
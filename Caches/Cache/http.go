@@ -1,24 +1,37 @@
 package cache
 
 import (
-	consistenthash "cache/consistentHash"
+	"bytes"
+	pb "cache/cachepb"
+	consistenthash "cache/consistenthash"
+	"cache/gossip"
+	"context"
+	"crypto/tls"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"net/url"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 )
 
 // http://example.com/_cache/
 const (
-	defaultBasePath = "/_cache/"
-	defaultReplicas = 50
+	defaultBasePath    = "/_cache/"
+	defaultMetricsPath = "/_metrics"
+	defaultPeersPath   = "/_peers"
+	defaultReplicas    = 50
 )
 
 type httpGetter struct {
 	baseURL string
+	// client 为空时使用 http.DefaultClient
+	client *http.Client
+	// 节点的健康状况，连续失败后熔断
+	health peerHealth
 }
 
 type HTTPPool struct {
@@ -33,6 +46,19 @@ type HTTPPool struct {
 	// 每一个远程节点对应一个 httpGetter，
 	// 因为 httpGetter 与远程节点的地址 baseURL 有关。
 	httpGetters map[string]*httpGetter
+
+	// gossip 不为空时，节点成员由 gossip 自动维护
+	gossip *gossip.Node
+
+	// admin 管理接口，挂载在 /_admin/ 下，为空表示未启用，见 EnableAdmin
+	admin http.Handler
+
+	// tlsConfig 和 secret 见 SetTLS 与 SetSecret，client 为据此创建的 http.Client，为空时使用默认客户端
+	tlsConfig *tls.Config
+	secret    []byte
+	client    *http.Client
+	// nonces 签名校验时记录已用过的随机数，拒绝重放的请求
+	nonces nonceCache
 }
 
 func NewHTTPPool(self string) *HTTPPool {
@@ -42,13 +68,67 @@ func NewHTTPPool(self string) *HTTPPool {
 	}
 }
 
+// EnableAdmin 在节点监听的地址上提供 /_admin/ 管理接口与 /_peers 成员管理接口，默认不提供。
+// 这两个接口可以删除缓存、增删节点，未调用 SetSecret 时任何能访问节点端口的客户端都能调用，
+// 只应在可信网络中或开启签名后启用。需要在开始处理请求之前调用
+func (p *HTTPPool) EnableAdmin() {
+	p.admin = NewAdminHandler()
+}
+
 // Log 打印服务器日志信息
 func (p *HTTPPool) Log(format string, v ...interface{}) {
 	log.Printf("[Server %s] %s", p.self, fmt.Sprintf(format, v...))
 }
 
-// ServeHTTP 处理其他节点的拉取请求，路径格式为 /basePath/group/key
+// ServeHTTP 处理其他节点的请求，路径格式为 /basePath/group/key
+// GET 拉取缓存，PUT 写入缓存，DELETE 删除缓存，请求体与响应体均为 cachepb 消息；
+// POST /basePath/group/ 批量拉取缓存，请求体为 pb.BatchRequest，响应体为 pb.BatchResponse
+// 另外在 /_metrics 以 Prometheus 文本格式暴露统计信息，在 /_generation 接收广播的代数，
+// 启用 gossip 后在 /_gossip 与其他节点交换成员列表，调用 EnableAdmin 后在 /_peers 管理节点成员、
+// 在 /_admin/ 提供管理接口（见 NewAdminHandler）
 func (p *HTTPPool) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// 开启签名校验后，除只读的统计信息外均要求合法签名
+	if len(p.secret) > 0 && r.URL.Path != defaultMetricsPath {
+		if err := verifyRequest(p.secret, &p.nonces, w, r); err != nil {
+			p.Log("Reject %s %s: %v", r.Method, r.URL.Path, err)
+			rejectRequest(w, err)
+			return
+		}
+	}
+
+	if strings.HasPrefix(r.URL.Path, defaultAdminPath) {
+		if p.admin != nil {
+			p.admin.ServeHTTP(w, r)
+		} else {
+			http.NotFound(w, r)
+		}
+		return
+	}
+
+	switch r.URL.Path {
+	case gossip.Path:
+		if p.gossip != nil {
+			p.gossip.ServeHTTP(w, r)
+		} else {
+			http.NotFound(w, r)
+		}
+		return
+	case defaultMetricsPath:
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		writeMetrics(w)
+		return
+	case defaultPeersPath:
+		if p.admin != nil {
+			p.servePeers(w, r)
+		} else {
+			http.NotFound(w, r)
+		}
+		return
+	case defaultGenerationPath:
+		p.serveGeneration(w, r)
+		return
+	}
+
 	// 1. 路径校验：必须以 basePath 开头，否则说明不是 geecache 的请求
 	if !strings.HasPrefix(r.URL.Path, p.basePath) {
 		panic("HTTPPool serving unexpected path: " + r.URL.Path)
@@ -56,33 +136,148 @@ func (p *HTTPPool) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	p.Log("%s %s", r.Method, r.URL.Path)
 
 	// 2. 解析路径 期望格式 /<basePath>/<group>/<key>
-	parts := strings.SplitN(r.URL.Path[len(p.basePath):], "/", 2)
-
-	if len(parts) != 2 {
-		http.Error(w, "bad request", http.StatusBadRequest)
+	// 在转义后的路径上切分再各自解码，key 中的 "/" 以 %2F 传输，不会被当成分隔符
+	groupName, key, ok := splitPath(r.URL.EscapedPath(), p.basePath)
+	if !ok {
+		writeResponse(w, r, &pb.Response{Code: pb.CodeBadRequest, Error: "bad request"})
 		return
 	}
 
-	groupName := parts[0]
-	key := parts[1]
-
 	// 3. 根据 groupName 获取对应的 Group 实例
 	group := GetGroup(groupName)
 	if group == nil {
-		http.Error(w, "no such group: "+groupName, http.StatusNotFound)
+		writeResponse(w, r, &pb.Response{Code: pb.CodeNotFound, Error: "no such group: " + groupName})
 		return
 	}
+	group.stats.serverRequests.Add(1)
 
-	// 4. 读取缓存（内部会处理缓存命中/回源逻辑）
-	view, err := group.Get(key)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if r.Method == http.MethodPost {
+		p.serveBatch(w, r, group, key)
 		return
 	}
 
-	// 5. 返回二进制数据。ByteView.ByteSlice() 会生成一个新的拷贝，避免共享底层数组
-	w.Header().Set("Content-Type", "application/octet-stream")
-	w.Write(view.ByteSlice())
+	switch r.Method {
+	case http.MethodPut:
+		// 4. 写入请求：请求体为 pb.Request，只写入本节点
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeResponse(w, r, &pb.Response{Code: pb.CodeBadRequest, Error: err.Error()})
+			return
+		}
+		req := &pb.Request{}
+		if err := req.Unmarshal(body); err != nil {
+			writeResponse(w, r, &pb.Response{Code: pb.CodeBadRequest, Error: err.Error()})
+			return
+		}
+		group.advanceGeneration(req.Gen)
+		group.setLocally(key, req.Value, req.TTL)
+		writeResponse(w, r, &pb.Response{Gen: group.Generation()})
+	case http.MethodDelete:
+		// 4. 删除请求：只删除本节点的缓存，不再向其他节点转发
+		group.advanceGeneration(queryGeneration(r))
+		group.removeLocally(key)
+		writeResponse(w, r, &pb.Response{Gen: group.Generation()})
+	default:
+		// 4. 读取缓存（内部会处理缓存命中/回源逻辑），返回值及剩余存活时间
+		// 使用请求的 ctx，请求方放弃后本节点也停止加载
+		res, view := group.lookupResponse(r.Context(), key, queryGeneration(r))
+		if view.compressed && acceptsGzip(r) {
+			writeGzipResponse(w, res, view)
+			return
+		}
+		if view.compressed {
+			res.Value = view.uncompressed()
+		}
+		writeResponse(w, r, res)
+	}
+}
+
+// serveBatch 处理批量读请求，请求路径中不能带 key
+func (p *HTTPPool) serveBatch(w http.ResponseWriter, r *http.Request, group *Group, key string) {
+	res := &pb.BatchResponse{}
+	body, err := io.ReadAll(r.Body)
+	req := &pb.BatchRequest{}
+	switch {
+	case key != "":
+		res.Code, res.Error = pb.CodeBadRequest, "batch request should not have a key"
+	case err != nil:
+		res.Code, res.Error = pb.CodeBadRequest, err.Error()
+	default:
+		if err := req.Unmarshal(body); err != nil {
+			res.Code, res.Error = pb.CodeBadRequest, err.Error()
+		} else {
+			res = group.getMultiResponse(r.Context(), req.Keys, req.Gen)
+		}
+	}
+	writeMessage(w, r, res.Code, res.Marshal())
+}
+
+// splitPath 从转义后的请求路径中解析出 group 和 key
+func splitPath(escaped, basePath string) (group, key string, ok bool) {
+	if !strings.HasPrefix(escaped, basePath) {
+		return "", "", false
+	}
+	parts := strings.SplitN(escaped[len(basePath):], "/", 2)
+	if len(parts) != 2 {
+		return "", "", false
+	}
+	group, err := url.PathUnescape(parts[0])
+	if err != nil {
+		return "", "", false
+	}
+	key, err = url.PathUnescape(parts[1])
+	if err != nil {
+		return "", "", false
+	}
+	return group, key, true
+}
+
+// httpStatus 将响应码映射为 HTTP 状态码，便于非 cache 客户端（如 curl）理解
+// Group 不存在是请求本身有误（节点配置不一致），与 key 不存在的 404 区分开
+var httpStatus = map[pb.Code]int{
+	pb.CodeOK:          http.StatusOK,
+	pb.CodeBadRequest:  http.StatusBadRequest,
+	pb.CodeNotFound:    http.StatusBadRequest,
+	pb.CodeInternal:    http.StatusInternalServerError,
+	pb.CodeKeyNotFound: http.StatusNotFound,
+}
+
+// writeResponse 编码并写出响应
+func writeResponse(w http.ResponseWriter, r *http.Request, res *pb.Response) {
+	writeMessage(w, r, res.Code, res.Marshal())
+}
+
+// writeMessage 写出已编码的消息，HTTP 状态码由 code 决定
+// 消息较大且请求方接受 gzip 时压缩后写出
+func writeMessage(w http.ResponseWriter, r *http.Request, code pb.Code, data []byte) {
+	status, ok := httpStatus[code]
+	if !ok {
+		status = http.StatusInternalServerError
+	}
+	w.Header().Set("Content-Type", pb.ContentType)
+	w.Header().Add("Vary", "Accept-Encoding")
+	if len(data) >= minTransferCompressSize && acceptsGzip(r) {
+		if z := gzipBytes(data); len(z) < len(data) {
+			w.Header().Set("Content-Encoding", "gzip")
+			data = z
+		}
+	}
+	w.WriteHeader(status)
+	w.Write(data)
+}
+
+// writeGzipResponse 写出 Value 为压缩存储的值的响应，不必解压后再重新压缩：
+// gzip 允许多个成员首尾相接，解压结果为各成员数据的拼接，
+// 因此压缩存储的值（本身即是完整的 gzip 数据）可以原样作为中间的成员写出
+func writeGzipResponse(w http.ResponseWriter, res *pb.Response, value ByteView) {
+	head, tail := res.MarshalAround(value.Len())
+	w.Header().Set("Content-Type", pb.ContentType)
+	w.Header().Add("Vary", "Accept-Encoding")
+	w.Header().Set("Content-Encoding", "gzip")
+	w.WriteHeader(http.StatusOK)
+	w.Write(gzipBytes(head))
+	w.Write(value.b)
+	w.Write(gzipBytes(tail))
 }
 
 // Set 根据给定地址列表初始化一致性哈希环， 并未每个地址创建 httpGetter客户端
@@ -91,62 +286,259 @@ func (p *HTTPPool) Set(peers ...string) {
 	defer p.mu.Unlock()
 
 	p.peers = consistenthash.New(defaultReplicas, nil)
-	p.peers.Add(peers...)
 	p.httpGetters = make(map[string]*httpGetter, len(peers))
+	p.addPeers(peers...)
+}
+
+// AddPeer 向运行中的集群加入节点，已存在的节点会被忽略
+// 只有落在新节点虚拟节点上的 key 会迁移，其余 key 的归属不变
+func (p *HTTPPool) AddPeer(peers ...string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.peers == nil {
+		p.peers = consistenthash.New(defaultReplicas, nil)
+		p.httpGetters = make(map[string]*httpGetter, len(peers))
+	}
+	p.addPeers(peers...)
+}
+
+// addPeers 调用方需持有 mu
+func (p *HTTPPool) addPeers(peers ...string) {
 	for _, peer := range peers {
+		if _, ok := p.httpGetters[peer]; ok {
+			continue
+		}
+		p.peers.Add(peer)
 		p.httpGetters[peer] = &httpGetter{
 			baseURL: peer + p.basePath,
+			client:  p.client,
+		}
+	}
+}
+
+// RemovePeer 将节点移出集群，原本归属该节点的 key 迁移到哈希环上的下一个节点
+func (p *HTTPPool) RemovePeer(peers ...string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, peer := range peers {
+		if _, ok := p.httpGetters[peer]; !ok {
+			continue
+		}
+		p.peers.Remove(peer)
+		delete(p.httpGetters, peer)
+	}
+}
+
+// StartGossip 通过 gossip 从种子节点发现其他节点，并自动维护一致性哈希环：
+// 发现的节点加入哈希环，失效的节点从哈希环中移除
+// 需要在开始处理请求之前调用
+func (p *HTTPPool) StartGossip(seeds ...string) *gossip.Node {
+	p.AddPeer(p.self)
+	p.gossip = gossip.New(p.self, seeds, func(addr string) {
+		p.AddPeer(addr)
+	}, func(addr string) {
+		p.RemovePeer(addr)
+	})
+	if p.client != nil {
+		p.gossip.Client.Transport = p.client.Transport
+	}
+	p.gossip.Start()
+	return p.gossip
+}
+
+// Peers 返回当前所有节点地址（升序）
+func (p *HTTPPool) Peers() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	peers := make([]string, 0, len(p.httpGetters))
+	for peer := range p.httpGetters {
+		peers = append(peers, peer)
+	}
+	sort.Strings(peers)
+	return peers
+}
+
+// servePeers 节点成员管理接口，无需重启进程即可增删节点
+// GET 列出所有节点，POST ?peer=<addr> 加入节点，DELETE ?peer=<addr> 移除节点
+func (p *HTTPPool) servePeers(w http.ResponseWriter, r *http.Request) {
+	peer := r.URL.Query().Get("peer")
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost, http.MethodDelete:
+		if peer == "" {
+			http.Error(w, "missing peer", http.StatusBadRequest)
+			return
+		}
+		if r.Method == http.MethodPost {
+			p.AddPeer(peer)
+		} else {
+			p.RemovePeer(peer)
 		}
+		p.Log("%s peer %s", r.Method, peer)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
 	}
 
+	w.Header().Set("Content-Type", "text/plain")
+	for _, peer := range p.Peers() {
+		fmt.Fprintln(w, peer)
+	}
 }
 
 // 包装了一致性哈希算法的 Get() 方法，
 // 根据具体的 key，选择节点，返回节点对应的 HTTP 客户端。
+// 沿哈希环顺时针跳过熔断中的节点；走到自身时返回 false，由本地加载。
+// 若选中节点之后、自身之前还有未熔断的节点，则将其作为副本，选中节点故障时重试一次。
 func (p *HTTPPool) PickPeer(key string) (PeerGetter, bool) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
+	if p.peers == nil {
+		return nil, false
+	}
+
+	var primary *httpGetter
+	for _, peer := range p.peers.GetN(key, len(p.httpGetters)) {
+		if peer == p.self {
+			break
+		}
+		getter := p.httpGetters[peer]
+		if primary == nil {
+			if getter.health.allow() {
+				primary = getter
+			}
+			continue
+		}
+		// 副本只在主节点失败时才会用到，不能占用其试探机会
+		if getter.health.healthy() {
+			return retryGetter{primary: primary, replica: getter}, true
+		}
+	}
+
+	if primary == nil {
+		return nil, false
+	}
+	return primary, true
+}
+
+// PickOwner 返回 key 的归属节点，不跳过熔断中的节点，供写请求使用
+// 归属节点是自身时返回 false
+func (p *HTTPPool) PickOwner(key string) (PeerGetter, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.peers == nil {
+		return nil, false
+	}
 	if peer := p.peers.Get(key); peer != "" && peer != p.self {
-		p.Log("Pick peer %s", peer)
 		return p.httpGetters[peer], true
 	}
-
 	return nil, false
 }
 
 var _PeerPicker = (*HTTPPool)(nil)
 
-// Get 向目标节点发起HTTP请求以获取缓存数据
-func (h *httpGetter) Get(group string, key string) ([]byte, error) {
-	// 拼接请求地址： <peer-base>/<group>/<key>
-	// 使用 url.QueryEscape 进行转义，避免特殊字符问题
+var _ OwnerPicker = (*HTTPPool)(nil)
+
+// url 拼接请求地址： <peer-base>/<group>/<key>
+// 使用 url.PathEscape 进行转义，空格、"+"、"/"、"%" 等字符都能原样还原
+// 没有请求体的 GET、DELETE 通过查询参数 gen 携带请求方的代数
+func (h *httpGetter) url(in *pb.Request) string {
 	u := fmt.Sprintf(
 		"%v%v/%v",
 		h.baseURL,
-		url.QueryEscape(group),
-		url.QueryEscape(key),
+		url.PathEscape(in.Group),
+		url.PathEscape(in.Key),
 	)
-	// 发起 GET 请求
-	res, err := http.Get(u)
+	if in.Gen > 0 {
+		u += "?gen=" + strconv.FormatUint(in.Gen, 10)
+	}
+	return u
+}
+
+// queryGeneration 读取查询参数 gen，没有或无法解析时返回 0
+func queryGeneration(r *http.Request) uint64 {
+	gen, _ := strconv.ParseUint(r.URL.Query().Get("gen"), 10, 64)
+	return gen
+}
+
+// message 节点返回的响应消息
+type message interface {
+	Unmarshal(data []byte) error
+	Err() error
+}
+
+// do 发起请求并解码响应，同时记录节点的健康状况
+// 网络错误或无法解码的响应原样返回，远程节点返回的错误为 *pb.Error
+func (h *httpGetter) do(ctx context.Context, method, url string, body io.Reader, out message) error {
+	err := h.roundTrip(ctx, method, url, body, out)
+	h.health.record(err)
+	return err
+}
+
+func (h *httpGetter) roundTrip(ctx context.Context, method, url string, body io.Reader, out message) error {
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", pb.ContentType)
+	}
+	// 显式声明接受 gzip，由下面自行解压，不依赖 http.Transport 的自动处理
+	req.Header.Set("Accept-Encoding", "gzip")
+	client := h.client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	// 关闭响应体
 	defer res.Body.Close()
 
-	//处理相应
-	if res.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("server returned: %v", res.Status)
-	}
-
 	// Read all
-	bytes, err := io.ReadAll(res.Body)
+	data, err := io.ReadAll(res.Body)
 	if err != nil {
-		return nil, fmt.Errorf("reading response body: %v", err)
+		return fmt.Errorf("reading response body: %v", err)
+	}
+	if res.Header.Get("Content-Type") != pb.ContentType {
+		return fmt.Errorf("server returned: %v", res.Status)
+	}
+	if res.Header.Get("Content-Encoding") == "gzip" {
+		if data, err = gunzipBytes(data); err != nil {
+			return fmt.Errorf("decompressing response body: %v", err)
+		}
+	}
+	if err := out.Unmarshal(data); err != nil {
+		return fmt.Errorf("decoding response body: %v", err)
 	}
+	return out.Err()
+}
+
+// Get 向目标节点发起 GET 请求以获取缓存数据
+func (h *httpGetter) Get(ctx context.Context, in *pb.Request, out *pb.Response) error {
+	return h.do(ctx, http.MethodGet, h.url(in), nil, out)
+}
+
+// GetMulti 向目标节点发起一次 POST 请求，批量获取缓存数据
+func (h *httpGetter) GetMulti(ctx context.Context, in *pb.BatchRequest, out *pb.BatchResponse) error {
+	return h.do(ctx, http.MethodPost, h.baseURL+url.PathEscape(in.Group)+"/", bytes.NewReader(in.Marshal()), out)
+}
+
+// Remove 向目标节点发起 DELETE 请求，删除其缓存数据
+func (h *httpGetter) Remove(ctx context.Context, in *pb.Request) error {
+	return h.do(ctx, http.MethodDelete, h.url(in), nil, &pb.Response{})
+}
 
-	return bytes, nil
+// Set 向目标节点发起 PUT 请求，将 in.Value 写入其缓存
+func (h *httpGetter) Set(ctx context.Context, in *pb.Request) error {
+	return h.do(ctx, http.MethodPut, h.url(in), bytes.NewReader(in.Marshal()), &pb.Response{})
 }
 
 // 编译期断言，确保 httpGetter 实现 PeerGetter 接口
 var _PeerGetter = (*httpGetter)(nil)
+
+var _ BatchPeerGetter = (*httpGetter)(nil)
@@ -0,0 +1,169 @@
+package cache
+
+import (
+	pb "cache/cachepb"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetrics(t *testing.T) {
+	g := newTestGroup(t, "metrics", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			return []byte(key), nil
+		}))
+	g.Get("jw")
+	g.Get("jw")
+
+	pool := NewHTTPPool("http://localhost:8001")
+	rec := httptest.NewRecorder()
+	pool.ServeHTTP(rec, httptest.NewRequest("GET", defaultMetricsPath, nil))
+
+	body, _ := io.ReadAll(rec.Body)
+	for _, want := range []string{
+		"# TYPE cache_gets_total counter",
+		`cache_gets_total{group="metrics"} 2`,
+		`cache_hits_total{group="metrics"} 1`,
+		`cache_items{group="metrics"} 1`,
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Fatalf("metrics missing %q:\n%s", want, body)
+		}
+	}
+}
+
+func TestHTTPGetter(t *testing.T) {
+	ctx := context.Background()
+	newTestGroup(t, "peer", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			return []byte(key), nil
+		}), WithTTL(time.Minute))
+	newTestGroup(t, "peer-missing", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			return nil, ErrNotFound
+		}), WithNegativeTTL(time.Second))
+	srv := httptest.NewServer(NewHTTPPool("peer"))
+	defer srv.Close()
+	h := &httpGetter{baseURL: srv.URL + defaultBasePath}
+
+	res := &pb.Response{}
+	if err := h.Get(ctx, &pb.Request{Group: "peer", Key: "jw"}, res); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if string(res.Value) != "jw" || res.TTL <= 0 || res.TTL > time.Minute {
+		t.Fatalf("unexpected response %+v", res)
+	}
+
+	// 远程节点返回的错误与传输错误可以区分开
+	var pe *pb.Error
+	err := h.Get(ctx, &pb.Request{Group: "unknown", Key: "jw"}, &pb.Response{})
+	if !errors.As(err, &pe) || pe.Code != pb.CodeNotFound {
+		t.Fatalf("unknown group should return CodeNotFound, got %v", err)
+	}
+
+	// key 不存在时返回 CodeKeyNotFound 及负缓存时间
+	res = &pb.Response{}
+	err = h.Get(ctx, &pb.Request{Group: "peer-missing", Key: "jw"}, res)
+	if !errors.As(err, &pe) || pe.Code != pb.CodeKeyNotFound || res.TTL != time.Second {
+		t.Fatalf("missing key should return CodeKeyNotFound, got %v, ttl %v", err, res.TTL)
+	}
+	batch := &pb.BatchResponse{}
+	if err := h.GetMulti(ctx, &pb.BatchRequest{Group: "peer-missing", Keys: []string{"jw", "Sam"}}, batch); err != nil ||
+		len(batch.Responses) != 2 || batch.Responses[1].Code != pb.CodeKeyNotFound {
+		t.Fatalf("unexpected batch response %+v, err %v", batch, err)
+	}
+	batch = &pb.BatchResponse{}
+	if err := h.GetMulti(ctx, &pb.BatchRequest{Group: "peer", Keys: []string{"jw", "Sam"}}, batch); err != nil ||
+		len(batch.Responses) != 2 || string(batch.Responses[1].Value) != "Sam" {
+		t.Fatalf("unexpected batch response %+v, err %v", batch, err)
+	}
+
+	rec := httptest.NewRecorder()
+	NewHTTPPool("peer").ServeHTTP(rec, httptest.NewRequest("GET", defaultBasePath+"peer-missing/jw", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("missing key should return 404, got %d", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	NewHTTPPool("peer").ServeHTTP(rec, httptest.NewRequest("GET", defaultBasePath+"unknown/jw", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("unknown group should return 400, got %d", rec.Code)
+	}
+
+	srv.Close()
+	err = h.Get(ctx, &pb.Request{Group: "peer", Key: "jw"}, &pb.Response{})
+	if err == nil || errors.As(err, &pe) {
+		t.Fatalf("closed server should return transport error, got %v", err)
+	}
+}
+
+func TestHTTPGetterEscaping(t *testing.T) {
+	ctx := context.Background()
+	newTestGroup(t, "escape", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			return []byte(key), nil
+		}))
+	srv := httptest.NewServer(NewHTTPPool("escape"))
+	defer srv.Close()
+	h := &httpGetter{baseURL: srv.URL + defaultBasePath}
+
+	get := func(key string) string {
+		t.Helper()
+		res := &pb.Response{}
+		if err := h.Get(ctx, &pb.Request{Group: "escape", Key: key}, res); err != nil {
+			t.Fatalf("Get(%q) failed: %v", key, err)
+		}
+		return string(res.Value)
+	}
+	for _, key := range []string{"a b", "a+b", "a/b", "a%20b", "/a//b/"} {
+		if v := get(key); v != key {
+			t.Fatalf("Get(%q) = %q", key, v)
+		}
+		if err := h.Set(ctx, &pb.Request{Group: "escape", Key: key, Value: []byte("set " + key)}); err != nil {
+			t.Fatalf("Set(%q) failed: %v", key, err)
+		}
+		if v := get(key); v != "set "+key {
+			t.Fatalf("Get(%q) after Set = %q", key, v)
+		}
+		if err := h.Remove(ctx, &pb.Request{Group: "escape", Key: key}); err != nil {
+			t.Fatalf("Remove(%q) failed: %v", key, err)
+		}
+		if v := get(key); v != key {
+			t.Fatalf("Get(%q) after Remove = %q", key, v)
+		}
+	}
+}
+
+func TestPeersEndpoint(t *testing.T) {
+	pool := NewHTTPPool("http://localhost:8001")
+	pool.Set("http://localhost:8001", "http://localhost:8002")
+
+	serve := func(method, target string) string {
+		rec := httptest.NewRecorder()
+		pool.ServeHTTP(rec, httptest.NewRequest(method, target, nil))
+		return rec.Body.String()
+	}
+
+	// 默认不提供成员管理接口
+	serve("POST", defaultPeersPath+"?peer=http://localhost:8003")
+	if got := pool.Peers(); len(got) != 2 {
+		t.Fatalf("peers endpoint should be disabled by default, got %v", got)
+	}
+	pool.EnableAdmin()
+
+	serve("POST", defaultPeersPath+"?peer=http://localhost:8003")
+	got := serve("DELETE", defaultPeersPath+"?peer=http://localhost:8002")
+	if want := "http://localhost:8001\nhttp://localhost:8003\n"; got != want {
+		t.Fatalf("peers %q, want %q", got, want)
+	}
+
+	// 只剩自身时所有 key 都在本地加载
+	pool.RemovePeer("http://localhost:8003")
+	if _, ok := pool.PickPeer("jw"); ok {
+		t.Fatalf("should not pick remote peer")
+	}
+}
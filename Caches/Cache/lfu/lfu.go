@@ -0,0 +1,223 @@
+package lfu
+
+import (
+	"cache/lru"
+	"container/list"
+	"sort"
+	"time"
+)
+
+// Value 与 lru.Value 相同，使用 Len() 方法返回占用的内存大小
+type Value = lru.Value
+
+// Cache LFU缓存
+// 按访问次数分桶，每个桶是一条双向链表，同一访问次数内按 LRU 顺序淘汰，
+// 增删改查均为 O(1)
+type Cache struct {
+	maxBytes int64
+	nbytes   int64
+	// 哈希表，key -> 链表节点
+	cache map[string]*list.Element
+	// 访问次数 -> 该次数下的节点链表，front 为最近访问
+	freqs map[int]*list.List
+	// 当前最小访问次数，淘汰时从该桶取节点
+	minFreq int
+
+	// 淘汰回调，可选
+	OnEvicted func(key string, value Value)
+}
+
+type entry struct {
+	key    string
+	value  Value
+	expire time.Time // 过期时间，零值表示永不过期
+	freq   int
+}
+
+func (e *entry) expired(now time.Time) bool {
+	return !e.expire.IsZero() && now.After(e.expire)
+}
+
+// New 创建一个新的 Cache
+func New(maxBytes int64, onEvicted func(string, Value)) *Cache {
+	return &Cache{
+		maxBytes:  maxBytes,
+		cache:     make(map[string]*list.Element),
+		freqs:     make(map[int]*list.List),
+		OnEvicted: onEvicted,
+	}
+}
+
+// Get 查找 key，命中时访问次数加一；已过期的节点视为未命中并删除
+func (c *Cache) Get(key string) (value Value, ok bool) {
+	ele, ok := c.cache[key]
+	if !ok {
+		return nil, false
+	}
+	kv := ele.Value.(*entry)
+	if kv.expired(time.Now()) {
+		c.removeElement(ele)
+		return nil, false
+	}
+	c.touch(ele)
+	return kv.value, true
+}
+
+// Peek 查找 key 对应的值，不更新访问记录和访问次数，已过期的节点视为不存在
+func (c *Cache) Peek(key string) (value Value, ok bool) {
+	if ele, ok := c.cache[key]; ok {
+		kv := ele.Value.(*entry)
+		if !kv.expired(time.Now()) {
+			return kv.value, true
+		}
+	}
+	return nil, false
+}
+
+// touch 将节点移到访问次数加一的桶中，返回新的节点
+func (c *Cache) touch(ele *list.Element) *list.Element {
+	kv := ele.Value.(*entry)
+	old := c.freqs[kv.freq]
+	old.Remove(ele)
+	if old.Len() == 0 {
+		delete(c.freqs, kv.freq)
+		if c.minFreq == kv.freq {
+			c.minFreq++
+		}
+	}
+	kv.freq++
+	ele = c.bucket(kv.freq).PushFront(kv)
+	c.cache[kv.key] = ele
+	return ele
+}
+
+// bucket 返回访问次数为 freq 的桶，不存在时创建
+func (c *Cache) bucket(freq int) *list.List {
+	l, ok := c.freqs[freq]
+	if !ok {
+		l = list.New()
+		c.freqs[freq] = l
+	}
+	return l
+}
+
+// Add 添加/更新节点，永不过期
+func (c *Cache) Add(key string, value Value) {
+	c.AddWithExpire(key, value, time.Time{})
+}
+
+// AddWithExpire 添加/更新节点并指定过期时间，expire 为零值时表示永不过期
+// 更新已有节点视为一次访问；新节点访问次数为 1
+// 超出最大内存限制时，淘汰访问次数最少的节点。新节点在插入前腾出空间，
+// 否则刚插入的新节点访问次数最少，会被立即淘汰
+func (c *Cache) AddWithExpire(key string, value Value, expire time.Time) {
+	if ele, ok := c.cache[key]; ok {
+		kv := ele.Value.(*entry)
+		c.nbytes += int64(value.Len()) - int64(kv.value.Len())
+		kv.value = value
+		kv.expire = expire
+		c.touch(ele)
+	} else {
+		size := int64(len(key)) + int64(value.Len())
+		for c.maxBytes != 0 && len(c.cache) > 0 && c.nbytes+size > c.maxBytes {
+			c.RemoveOldest()
+		}
+		kv := &entry{key: key, value: value, expire: expire, freq: 1}
+		c.cache[key] = c.bucket(1).PushFront(kv)
+		c.minFreq = 1
+		c.nbytes += size
+	}
+	for c.maxBytes != 0 && c.nbytes > c.maxBytes {
+		c.RemoveOldest()
+	}
+}
+
+// RemoveOldest 淘汰访问次数最少的节点，次数相同时淘汰最久未访问的
+func (c *Cache) RemoveOldest() {
+	if len(c.cache) == 0 {
+		return
+	}
+	c.removeElement(c.freqs[c.minFreq].Back())
+}
+
+// Remove 删除指定 key 对应的节点，返回 key 是否存在
+func (c *Cache) Remove(key string) bool {
+	if ele, ok := c.cache[key]; ok {
+		c.removeElement(ele)
+		return true
+	}
+	return false
+}
+
+// RemoveExpired 清理所有已过期的节点，返回清理的数量
+func (c *Cache) RemoveExpired() int {
+	now := time.Now()
+	n := 0
+	for _, ele := range c.cache {
+		if ele.Value.(*entry).expired(now) {
+			c.removeElement(ele)
+			n++
+		}
+	}
+	return n
+}
+
+// removeElement 从桶和哈希表中删除节点，并调用淘汰回调
+func (c *Cache) removeElement(ele *list.Element) {
+	kv := ele.Value.(*entry)
+	l := c.freqs[kv.freq]
+	l.Remove(ele)
+	if l.Len() == 0 {
+		delete(c.freqs, kv.freq)
+		if c.minFreq == kv.freq {
+			c.resetMinFreq()
+		}
+	}
+	delete(c.cache, kv.key)
+	c.nbytes -= int64(len(kv.key)) + int64(kv.value.Len())
+	if c.OnEvicted != nil {
+		c.OnEvicted(kv.key, kv.value)
+	}
+}
+
+// Range 按访问次数从少到多、同一次数内从旧到新遍历未过期的节点，fn 返回 false 时停止遍历
+func (c *Cache) Range(fn func(key string, value Value, expire time.Time) bool) {
+	freqs := make([]int, 0, len(c.freqs))
+	for freq := range c.freqs {
+		freqs = append(freqs, freq)
+	}
+	sort.Ints(freqs)
+
+	now := time.Now()
+	for _, freq := range freqs {
+		for ele := c.freqs[freq].Back(); ele != nil; ele = ele.Prev() {
+			kv := ele.Value.(*entry)
+			if kv.expired(now) {
+				continue
+			}
+			if !fn(kv.key, kv.value, kv.expire) {
+				return
+			}
+		}
+	}
+}
+
+// resetMinFreq 最小访问次数的桶被删空后，重新计算 minFreq
+func (c *Cache) resetMinFreq() {
+	c.minFreq = 0
+	for freq := range c.freqs {
+		if c.minFreq == 0 || freq < c.minFreq {
+			c.minFreq = freq
+		}
+	}
+}
+
+// Len 返回当前缓存的节点数量
+func (c *Cache) Len() int {
+	return len(c.cache)
+}
+
+// Bytes 返回当前缓存占用的字节数
+func (c *Cache) Bytes() int64 {
+	return c.nbytes
+}
@@ -0,0 +1,64 @@
+package lfu
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type String string
+
+func (d String) Len() int {
+	return len(d)
+}
+
+func TestGet(t *testing.T) {
+	lfu := New(int64(0), nil)
+	lfu.Add("key1", String("456"))
+
+	if v, ok := lfu.Get("key1"); !ok || string(v.(String)) != "456" {
+		t.Fatalf("cache hit key1=456 failed")
+	}
+	if _, ok := lfu.Get("key2"); ok {
+		t.Fatalf("cache miss key2 failed")
+	}
+}
+
+// TestEvictLeastFrequent 访问次数最少的节点先被淘汰，次数相同时淘汰最久未访问的
+func TestEvictLeastFrequent(t *testing.T) {
+	keys := make([]string, 0)
+	lfu := New(int64(6), func(key string, value Value) {
+		keys = append(keys, key)
+	})
+	lfu.Add("k1", String("1"))
+	lfu.Add("k2", String("2"))
+	lfu.Get("k1")
+	lfu.Add("k3", String("3")) // 淘汰 k2
+	lfu.Get("k3")
+	lfu.Get("k3")
+	lfu.Add("k4", String("4")) // 淘汰 k1
+
+	if !reflect.DeepEqual(keys, []string{"k2", "k1"}) {
+		t.Fatalf("OnEvicted keys %v, expect [k2 k1]", keys)
+	}
+	if _, ok := lfu.Get("k3"); !ok || lfu.Len() != 2 || lfu.Bytes() != 6 {
+		t.Fatalf("k3 should survive, len %d bytes %d", lfu.Len(), lfu.Bytes())
+	}
+}
+
+func TestExpireAndRemove(t *testing.T) {
+	lfu := New(int64(0), nil)
+	lfu.AddWithExpire("key1", String("1"), time.Now().Add(-time.Second))
+	lfu.AddWithExpire("key2", String("2"), time.Now().Add(-time.Second))
+	lfu.Add("key3", String("3"))
+
+	if _, ok := lfu.Get("key1"); ok {
+		t.Fatalf("expired key1 should miss")
+	}
+	if n := lfu.RemoveExpired(); n != 1 {
+		t.Fatalf("RemoveExpired removed %d, expect 1", n)
+	}
+	if !lfu.Remove("key3") || lfu.Len() != 0 || lfu.Bytes() != 0 {
+		t.Fatalf("Remove key3 failed")
+	}
+}
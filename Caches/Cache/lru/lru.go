@@ -2,6 +2,7 @@ package lru
 
 import (
 	"container/list"
+	"time"
 )
 
 // cache LRU缓存
@@ -21,6 +22,13 @@ type Cache struct {
 type entry struct {
 	key   string
 	value Value
+	// 过期时间，零值表示永不过期
+	expire time.Time
+}
+
+// expired 判断节点在 now 时刻是否已经过期
+func (e *entry) expired(now time.Time) bool {
+	return !e.expire.IsZero() && now.After(e.expire)
 }
 
 // Value 使用 Len() 方法返回占用的内存大小
@@ -40,31 +48,77 @@ func New(maxBytes int64, onEvicted func(string, Value)) *Cache {
 
 // 查找功能 Get
 // 如果 key 存在，则将对应节点移到队尾（表示最近使用过），并返回值
+// 已过期的节点视为未命中，并顺便将其删除
 func (c *Cache) Get(key string) (value Value, ok bool) {
 	if ele, ok := c.cache[key]; ok {
+		ret := ele.Value.(*entry)
+		if ret.expired(time.Now()) {
+			c.removeElement(ele)
+			return nil, false
+		}
 		//双向链表作为队列，队首队尾是相对的，这里约定front为队尾
 		c.ll.MoveToFront(ele)
-		ret := ele.Value.(*entry)
 		return ret.value, true
 	}
 	return
 }
 
+// Peek 查找 key 对应的值，不更新访问记录，已过期的节点视为不存在
+func (c *Cache) Peek(key string) (value Value, ok bool) {
+	if ele, ok := c.cache[key]; ok {
+		kv := ele.Value.(*entry)
+		if !kv.expired(time.Now()) {
+			return kv.value, true
+		}
+	}
+	return nil, false
+}
+
 // 移除最近最少访问的节点（队首）
 func (c *Cache) RemoveOldest() {
 	ele := c.ll.Back()
 	if ele != nil {
-		c.ll.Remove(ele)
-		kv := ele.Value.(*entry)
+		c.removeElement(ele)
+	}
+}
+
+// Remove 删除指定 key 对应的节点，返回 key 是否存在
+func (c *Cache) Remove(key string) bool {
+	if ele, ok := c.cache[key]; ok {
+		c.removeElement(ele)
+		return true
+	}
+	return false
+}
 
-		// 从哈希表中删除
-		delete(c.cache, kv.key)
-		// 更新当前内存大小
-		c.nbytes -= int64(len(kv.key)) + int64(kv.value.Len())
-		// 调用回调函数
-		if c.OnEvicted != nil {
-			c.OnEvicted(kv.key, kv.value)
+// RemoveExpired 清理所有已过期的节点，返回清理的数量
+// 供后台定时清理使用，被清理的节点同样会触发 OnEvicted
+func (c *Cache) RemoveExpired() int {
+	now := time.Now()
+	n := 0
+	for ele := c.ll.Back(); ele != nil; {
+		prev := ele.Prev()
+		if ele.Value.(*entry).expired(now) {
+			c.removeElement(ele)
+			n++
 		}
+		ele = prev
+	}
+	return n
+}
+
+// removeElement 从链表和哈希表中删除节点，并调用淘汰回调
+func (c *Cache) removeElement(ele *list.Element) {
+	c.ll.Remove(ele)
+	kv := ele.Value.(*entry)
+
+	// 从哈希表中删除
+	delete(c.cache, kv.key)
+	// 更新当前内存大小
+	c.nbytes -= int64(len(kv.key)) + int64(kv.value.Len())
+	// 调用回调函数
+	if c.OnEvicted != nil {
+		c.OnEvicted(kv.key, kv.value)
 	}
 }
 
@@ -73,14 +127,21 @@ func (c *Cache) RemoveOldest() {
 // 若 key 不存在，则新建节点并插入到队尾
 // 添加新节点后，若超出最大内存限制，则移除最近最少访问的节点
 func (c *Cache) Add(key string, value Value) {
+	c.AddWithExpire(key, value, time.Time{})
+}
+
+// AddWithExpire 与 Add 相同，但额外指定节点的过期时间
+// expire 为零值时表示永不过期
+func (c *Cache) AddWithExpire(key string, value Value, expire time.Time) {
 	if ele, ok := c.cache[key]; ok {
 		c.ll.MoveToFront(ele)
 		kv := ele.Value.(*entry)
 		c.nbytes += int64(value.Len()) - int64(kv.value.Len())
 		kv.value = value
+		kv.expire = expire
 	} else {
 		// 若 key 不存在，新增节点
-		ele := c.ll.PushFront(&entry{key, value})
+		ele := c.ll.PushFront(&entry{key, value, expire})
 		c.cache[key] = ele
 		c.nbytes += int64(len(key)) + int64(value.Len())
 
@@ -94,3 +155,23 @@ func (c *Cache) Add(key string, value Value) {
 func (c *Cache) Len() int {
 	return c.ll.Len()
 }
+
+// Bytes 返回当前缓存占用的字节数
+func (c *Cache) Bytes() int64 {
+	return c.nbytes
+}
+
+// Range 从最久未访问到最近访问依次遍历未过期的节点，fn 返回 false 时停止遍历
+// 按此顺序重新 Add 可以还原节点的新旧次序
+func (c *Cache) Range(fn func(key string, value Value, expire time.Time) bool) {
+	now := time.Now()
+	for ele := c.ll.Back(); ele != nil; ele = ele.Prev() {
+		kv := ele.Value.(*entry)
+		if kv.expired(now) {
+			continue
+		}
+		if !fn(kv.key, kv.value, kv.expire) {
+			return
+		}
+	}
+}
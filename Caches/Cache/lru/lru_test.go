@@ -3,6 +3,7 @@ package lru
 import (
 	"reflect"
 	"testing"
+	"time"
 )
 
 type String string
@@ -68,3 +69,83 @@ func TestAdd(t *testing.T) {
 		t.Fatalf("cache key1=456 failed")
 	}
 }
+
+// TestExpire 测试过期节点在 Get 时视为未命中
+func TestExpire(t *testing.T) {
+	keys := make([]string, 0)
+	lru := New(int64(0), func(key string, value Value) {
+		keys = append(keys, key)
+	})
+	lru.AddWithExpire("key1", String("123"), time.Now().Add(-time.Second))
+	lru.AddWithExpire("key2", String("456"), time.Now().Add(time.Hour))
+	lru.Add("key3", String("789"))
+
+	if _, ok := lru.Get("key1"); ok {
+		t.Fatalf("expired key1 should miss")
+	}
+	if _, ok := lru.Get("key2"); !ok {
+		t.Fatalf("key2 should not expire yet")
+	}
+	if _, ok := lru.Get("key3"); !ok {
+		t.Fatalf("key3 without expire should hit")
+	}
+	if lru.Len() != 2 || !reflect.DeepEqual(keys, []string{"key1"}) {
+		t.Fatalf("expired key1 should be evicted, got len %d evicted %v", lru.Len(), keys)
+	}
+}
+
+// TestRemoveExpired 测试批量清理过期节点
+func TestRemoveExpired(t *testing.T) {
+	keys := make([]string, 0)
+	lru := New(int64(0), func(key string, value Value) {
+		keys = append(keys, key)
+	})
+	past := time.Now().Add(-time.Second)
+	lru.AddWithExpire("key1", String("1"), past)
+	lru.Add("key2", String("2"))
+	lru.AddWithExpire("key3", String("3"), past)
+
+	if n := lru.RemoveExpired(); n != 2 || lru.Len() != 1 {
+		t.Fatalf("RemoveExpired removed %d, len %d", n, lru.Len())
+	}
+	if !reflect.DeepEqual(keys, []string{"key1", "key3"}) {
+		t.Fatalf("OnEvicted keys %v, expect [key1 key3]", keys)
+	}
+}
+
+// TestRemove 测试删除功能
+func TestRemove(t *testing.T) {
+	keys := make([]string, 0)
+	lru := New(int64(0), func(key string, value Value) {
+		keys = append(keys, key)
+	})
+	lru.Add("key1", String("123"))
+
+	if !lru.Remove("key1") || lru.Remove("key1") {
+		t.Fatalf("Remove key1 should succeed exactly once")
+	}
+	if _, ok := lru.Get("key1"); ok || lru.Len() != 0 {
+		t.Fatalf("key1 should be removed")
+	}
+	if !reflect.DeepEqual(keys, []string{"key1"}) {
+		t.Fatalf("OnEvicted keys %v, expect [key1]", keys)
+	}
+}
+
+// TestRange 测试按从旧到新的顺序遍历，并跳过已过期的节点
+func TestRange(t *testing.T) {
+	lru := New(int64(0), nil)
+	lru.Add("key1", String("1"))
+	lru.AddWithExpire("key2", String("2"), time.Now().Add(-time.Second))
+	lru.Add("key3", String("3"))
+	lru.Get("key1")
+
+	keys := make([]string, 0)
+	lru.Range(func(key string, value Value, expire time.Time) bool {
+		keys = append(keys, key)
+		return true
+	})
+	if !reflect.DeepEqual(keys, []string{"key3", "key1"}) {
+		t.Fatalf("Range keys %v, expect [key3 key1]", keys)
+	}
+}
@@ -3,20 +3,81 @@
 package cache
 
 import (
+	pb "cache/cachepb"
 	"cache/singleflight"
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
 	"log"
+	"math/rand"
+	"runtime/debug"
 	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// hotCacheRatio 从远程节点取回的值中，每 hotCacheRatio 个抽样一个放入 hotCache
+	hotCacheRatio = 10
+	// hotCacheDivisor hotCache 的容量为 mainCache 的 1/hotCacheDivisor
+	hotCacheDivisor = 8
+	// maxReplicaTTL hotCache 中副本的最长存活时间。归属节点上的 Set、Remove 不会通知持有副本的节点，
+	// 副本最多在该时间后重新从归属节点加载，即使原值永不过期
+	maxReplicaTTL = time.Minute
+	// writeSeqStripes 写序号的分段数，key 按哈希共用分段，
+	// 同一分段上的 Set、Remove 也会使其他 key 进行中的加载不写入缓存，只影响命中率
+	writeSeqStripes = 1024
 )
 
 type Group struct {
-	name      string
-	getter    Getter
-	mainCache cache
+	name   string
+	getter Getter
+	// mainCache 保存本节点负责（一致性哈希归属本节点）的 key
+	mainCache *shardedCache
+	// hotCache 保存归属其他节点、但访问频繁的 key 的副本，
+	// 使热点 key 在每个节点上都能本地命中，避免打爆归属节点
+	hotCache *shardedCache
+	// 缓存项的默认存活时间，0 表示永不过期
+	ttl time.Duration
+	// negativeTTL key 不存在的结果的缓存时间，0 表示不缓存
+	negativeTTL time.Duration
+	// staleTTL 缓存项过期后仍可返回旧值的时间，期间在后台重新加载，0 表示不返回旧值
+	staleTTL time.Duration
+	// refreshAhead 缓存项剩余存活时间不足 refreshAhead 时，命中会触发后台重新加载
+	refreshAhead time.Duration
+	// loadTimeout 单次加载的最长时间，见 loadContext
+	loadTimeout time.Duration
+	// compressThreshold 不小于该长度的值压缩后存储，0 表示不压缩
+	compressThreshold int
+	// generation 当前代数，递增后之前加载的缓存项全部失效，见 Invalidate
+	generation atomic.Uint64
+	// writeSeqs 按 key 哈希分段的写序号，Set、Remove 时递增，见 version
+	writeSeqs [writeSeqStripes]atomic.Uint64
 
 	peer   PeerPicker
 	loader *singleflight.Group
+	// refreshing 正在后台刷新的 key，同一 key 同时只启动一个刷新协程
+	refreshing sync.Map
+	// ctx 在 DestroyGroup 时取消，停止后台刷新与广播重试，见 outdated
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// 统计计数器
+	stats stats
+
+	// snapshotStop 关闭后停止定时保存快照
+	snapshotMu   sync.Mutex
+	snapshotStop chan struct{}
 }
 
+// ErrNotFound 表示 key 在数据源中不存在
+// Getter 返回的错误包装了 ErrNotFound 时，Group 可以在短时间内缓存该结果，见 WithNegativeTTL
+var ErrNotFound = errors.New("cache: key not found")
+
+// ErrLoadPanic Getter 或远程节点请求在加载时 panic，返回的错误包装了该错误，并附带 panic 的值与堆栈
+var ErrLoadPanic = errors.New("cache: load panicked")
+
 type Getter interface {
 	Get(key string) ([]byte, error)
 }
@@ -27,30 +88,127 @@ func (f GetterFunc) Get(key string) ([]byte, error) {
 	return f(key)
 }
 
+// ContextGetter 支持 context 的 Getter，回源时可以感知调用方的取消与超时
+// Group 回源时优先使用 GetContext
+type ContextGetter interface {
+	Getter
+	GetContext(ctx context.Context, key string) ([]byte, error)
+}
+
+// ContextGetterFunc 以函数实现 ContextGetter
+type ContextGetterFunc func(ctx context.Context, key string) ([]byte, error)
+
+func (f ContextGetterFunc) Get(key string) ([]byte, error) {
+	return f(context.Background(), key)
+}
+
+func (f ContextGetterFunc) GetContext(ctx context.Context, key string) ([]byte, error) {
+	return f(ctx, key)
+}
+
+// BatchGetter 支持批量回源的 Getter，GetMulti 未命中的 key 会合并为一次 GetMulti 调用
+// 返回结果中缺少的 key 视为不存在
+type BatchGetter interface {
+	Getter
+	GetMulti(ctx context.Context, keys []string) (map[string][]byte, error)
+}
+
+// BatchGetterFunc 以函数实现 BatchGetter
+type BatchGetterFunc func(ctx context.Context, keys []string) (map[string][]byte, error)
+
+func (f BatchGetterFunc) Get(key string) ([]byte, error) {
+	values, err := f(context.Background(), []string{key})
+	if err != nil {
+		return nil, err
+	}
+	if v, ok := values[key]; ok {
+		return v, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrNotFound, key)
+}
+
+func (f BatchGetterFunc) GetMulti(ctx context.Context, keys []string) (map[string][]byte, error) {
+	return f(ctx, keys)
+}
+
 var (
 	mu     sync.RWMutex
 	groups = make(map[string]*Group)
 )
 
-// NewGroup 创建一个新的缓存组
-func NewGroup(name string, cacheBytes int64, getter Getter) *Group {
+// ErrGroupExists 同名的 Group 已经存在，需要先 DestroyGroup 才能以新的配置重新创建
+var ErrGroupExists = errors.New("cache: group already exists")
+
+// NewGroup 创建一个新的缓存组，容量、TTL、淘汰策略、节点等通过 opts 设置，见 GroupOption
+// 同名的 Group 已经存在时返回 ErrGroupExists，不会替换已有的 Group
+func NewGroup(name string, getter Getter, opts ...GroupOption) (*Group, error) {
 	if getter == nil {
 		panic("nil Getter")
 	}
+	o := groupOptions{cacheBytes: defaultCacheBytes, loadTimeout: defaultLoadTimeout}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
 	mu.Lock()
 	defer mu.Unlock()
+	if _, ok := groups[name]; ok {
+		return nil, fmt.Errorf("%w: %s", ErrGroupExists, name)
+	}
 
+	hotBytes := hotCacheBytes(o.cacheBytes)
 	g := &Group{
-		name:   name,
-		getter: getter,
-		mainCache: cache{
-			cacheBytes: cacheBytes,
-		},
-		loader: &singleflight.Group{},
+		name:              name,
+		getter:            getter,
+		mainCache:         newShardedCache(o.cacheBytes, shardCount(o.cacheBytes), o.policy),
+		hotCache:          newShardedCache(hotBytes, shardCount(hotBytes), o.policy),
+		ttl:               o.ttl,
+		negativeTTL:       o.negativeTTL,
+		staleTTL:          o.staleTTL,
+		refreshAhead:      o.refreshAhead,
+		loadTimeout:       o.loadTimeout,
+		compressThreshold: o.compressThreshold,
+		peer:              o.peers,
+		loader:            &singleflight.Group{},
 	}
+	g.ctx, g.cancel = context.WithCancel(context.Background())
 
 	groups[name] = g
-	return g
+	return g, nil
+}
+
+// hotCacheBytes 返回 hotCache 的容量，mainCache 不限容量时 hotCache 也不限，否则至少为 1 字节
+func hotCacheBytes(cacheBytes int64) int64 {
+	if cacheBytes <= 0 {
+		return 0
+	}
+	return max(cacheBytes/hotCacheDivisor, 1)
+}
+
+// replicaTTL 返回副本的存活时间：不超过归属节点上的剩余存活时间，也不超过 maxReplicaTTL
+func replicaTTL(ttl time.Duration) time.Duration {
+	if ttl <= 0 || ttl > maxReplicaTTL {
+		return maxReplicaTTL
+	}
+	return ttl
+}
+
+// DestroyGroup 注销名为 name 的 Group：停止定时快照和后台刷新，丢弃本节点上的缓存，
+// 之后 GetGroup 与其他节点的请求都找不到该 Group，同名的 Group 可以重新创建；
+// 仍在进行的加载可以完成，但结果不再写入缓存。返回 false 表示 Group 不存在
+func DestroyGroup(name string) bool {
+	mu.Lock()
+	g, ok := groups[name]
+	delete(groups, name)
+	mu.Unlock()
+	if !ok {
+		return false
+	}
+
+	g.cancel()
+	g.StopSnapshots()
+	g.Purge()
+	return true
 }
 
 // GetGroup 根据名称获取已创建的Group，若不存在则返回nil
@@ -69,52 +227,343 @@ func GetGroup(name string) *Group {
 // getLocally 调用用户回调函数 g.getter.Get() 获取源数据，
 // 并且将源数据添加到缓存 mainCache 中（通过 populateCache 方法
 func (g *Group) Get(key string) (ByteView, error) {
+	return g.GetContext(context.Background(), key)
+}
+
+// GetContext 与 Get 相同，但 ctx 取消或超时后立即返回 ctx.Err()
+// ctx 会传递给 ContextGetter 和远程节点请求
+func (g *Group) GetContext(ctx context.Context, key string) (ByteView, error) {
+	v, err := g.lookup(ctx, key)
+	if err == nil && v.notFound {
+		return ByteView{}, fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+	return v, err
+}
+
+// lookup 依次查找 mainCache、hotCache，未命中时加载
+// 命中负缓存时返回 notFound 为 true 的 ByteView
+func (g *Group) lookup(ctx context.Context, key string) (ByteView, error) {
+	g.stats.gets.Add(1)
 	if key == "" {
 		return ByteView{}, nil
 	}
 
-	if v, ok := g.mainCache.get(key); ok {
-		log.Println("[Cache] hit")
+	if v, ok := g.getCached(key); ok {
 		return v, nil
 	}
+	return g.load(ctx, key)
+}
+
+// getCached 依次查找 mainCache 和 hotCache
+// 已过期、但仍在 staleTTL 内的值直接返回，同时在后台重新加载；
+// 剩余存活时间不足 refreshAhead 的值同样触发后台重新加载，使热点 key 不会真正过期。
+// 属于旧代数的值视为未命中，留待重新加载时覆盖或被淘汰
+func (g *Group) getCached(key string) (ByteView, bool) {
+	gen := g.generation.Load()
+	for _, cache := range []*shardedCache{g.mainCache, g.hotCache} {
+		v, ok := cache.get(key)
+		if !ok || v.ver.gen < gen {
+			continue
+		}
+		// 命中路径上不打日志：log 的全局锁会让分片锁失去意义，命中情况见 Stats
+		g.stats.hits.Add(1)
 
-	return g.load(key)
+		if !v.e.IsZero() && !v.notFound {
+			switch left := time.Until(v.e); {
+			case left <= 0:
+				g.stats.staleHits.Add(1)
+				g.refresh(key, cache)
+			case left <= g.refreshAhead:
+				g.refresh(key, cache)
+			}
+		}
+		return v, true
+	}
+	return ByteView{}, false
 }
 
-// load 表示“从源头加载数据”
-func (g *Group) load(key string) (value ByteView, err error) {
-	view1, err := g.loader.Do(key, func() (interface{}, error) {
-		if g.peer != nil {
-			if peer, ok := g.peer.PickPeer(key); ok {
-				if value, err := g.getFromPeer(peer, key); err == nil {
-					return value, nil
-				}
-				log.Println("[Cache] Failed to get from peer", err)
+// refresh 在后台重新加载 key，与同一 key 的其他加载共享 singleflight
+// 从远程节点取回的值只会抽样放入 hotCache，因此加载成功后写回 key 原来所在的缓存
+// 刷新未完成前再次命中不会重复启动协程
+func (g *Group) refresh(key string, cache *shardedCache) {
+	if g.ctx.Err() != nil {
+		return
+	}
+	if _, busy := g.refreshing.LoadOrStore(key, struct{}{}); busy {
+		return
+	}
+	ch := g.loader.DoChan(key, func() (_ interface{}, err error) {
+		log.Println("[Cache] refresh", key)
+		g.stats.refreshes.Add(1)
+		defer recoverLoad(key, &err)
+		// 后台刷新没有调用方，随 DestroyGroup 取消
+		ctx, cancel := context.WithTimeout(g.ctx, g.loadTimeout)
+		defer cancel()
+		return g.loadOnce(ctx, key)
+	})
+	go func() {
+		defer g.refreshing.Delete(key)
+		if r := <-ch; r.Err == nil {
+			value := r.Val.(ByteView)
+			if cache == g.hotCache {
+				// 与 fromPeer 相同，副本的存活时间不超过 maxReplicaTTL
+				value.e = expireAfter(replicaTTL(value.ttl()))
 			}
+			g.populateCache(key, value, cache)
 		}
-		return g.getLocally(key)
+	}()
+}
+
+// load 表示“从源头加载数据”
+// 相同 key 的并发请求经 singleflight 合并，实际加载使用 loadContext 派生的 ctx，
+// 不随第一个请求取消；每个等待者都可以通过自己的 ctx 单独放弃等待，不影响其他等待者
+func (g *Group) load(ctx context.Context, key string) (value ByteView, err error) {
+	g.stats.loads.Add(1)
+
+	// executed 记录 fn 是否由当前请求触发，未触发说明请求被 singleflight 合并
+	executed := false
+	ch := g.loader.DoChan(key, func() (_ interface{}, err error) {
+		executed = true
+		defer recoverLoad(key, &err)
+		loadCtx, cancel := g.loadContext(ctx)
+		defer cancel()
+		return g.loadOnce(loadCtx, key)
 	})
 
-	if err == nil {
-		return view1.(ByteView), nil
+	select {
+	case r := <-ch:
+		if !executed {
+			g.stats.loadsDeduped.Add(1)
+		}
+		if r.Err != nil {
+			return ByteView{}, r.Err
+		}
+		return r.Val.(ByteView), nil
+	case <-ctx.Done():
+		return ByteView{}, ctx.Err()
+	}
+}
+
+// recoverLoad 将加载过程中的 panic 转为错误返回给所有等待者
+// 加载经 DoChan 在单独的协程中进行，panic 传播出去只能使整个进程崩溃
+// 必须直接以 defer 调用，recover 才能生效
+func recoverLoad(key string, err *error) {
+	if r := recover(); r != nil {
+		*err = loadPanicError(key, r)
+	}
+}
+
+// loadPanicError 包装 panic 的值与当前堆栈，需要在 recover 所在的协程中调用
+func loadPanicError(key string, r interface{}) error {
+	return fmt.Errorf("%w: %s: %v\n\n%s", ErrLoadPanic, key, r, debug.Stack())
+}
+
+// loadContext 返回合并后的加载使用的 ctx：保留 ctx 中的值，但不随 ctx 取消，
+// 只在 loadTimeout 后超时，避免发起加载的请求断开时其他等待者一起失败
+func (g *Group) loadContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(ctx), g.loadTimeout)
+}
+
+// loadOnce 优先从归属节点加载，失败时回源到本地 Getter
+// 归属节点确认 key 不存在时直接返回，不再回源
+func (g *Group) loadOnce(ctx context.Context, key string) (interface{}, error) {
+	// 加载期间 Invalidate、Set 或 Remove 时，加载结果不再写入缓存
+	ver := g.version(key)
+	if g.peer != nil {
+		if peer, ok := g.peer.PickPeer(key); ok {
+			value, err := g.getFromPeer(ctx, peer, key, ver)
+			if err == nil || errors.Is(err, ErrNotFound) {
+				g.stats.peerLoads.Add(1)
+				return value, err
+			}
+			g.stats.peerErrors.Add(1)
+			log.Println("[Cache] Failed to get from peer", err)
+			// 调用方已放弃，不必再回源
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+		}
+	}
+	value, err := g.getLocally(ctx, key, ver)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		g.stats.localLoadErrs.Add(1)
+		return nil, err
+	}
+	g.stats.localLoads.Add(1)
+	return value, err
+}
+
+// getLocally 使用回调函数获取数据并添加到缓存，ver 为开始加载时的版本
+// Getter 返回 ErrNotFound 时按 negativeTTL 缓存负结果，错误仍原样返回
+func (g *Group) getLocally(ctx context.Context, key string, ver version) (ByteView, error) {
+	var bytes []byte
+	var err error
+	if getter, ok := g.getter.(ContextGetter); ok {
+		bytes, err = getter.GetContext(ctx, key)
+	} else {
+		bytes, err = g.getter.Get(key)
 	}
-	return
+	return g.populateLocal(key, bytes, err, ver)
 }
 
-// getLocally 使用回调函数获取数据并添加到缓存
-func (g *Group) getLocally(key string) (ByteView, error) {
-	bytes, err := g.getter.Get(key)
+// populateLocal 将回源结果写入 mainCache，不存在的 key 按 negativeTTL 缓存负结果
+func (g *Group) populateLocal(key string, bytes []byte, err error, ver version) (ByteView, error) {
 	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			if g.negativeTTL > 0 {
+				g.populateCache(key, ByteView{e: expireAfter(g.negativeTTL), notFound: true, ver: ver}, g.mainCache)
+			} else if !g.outdated(key, ver) {
+				// 数据源中已不存在，丢弃后台刷新前残留的旧值
+				g.mainCache.remove(key)
+			}
+		}
 		return ByteView{}, err
 	}
 
-	value := ByteView{b: cloneBytes(bytes)}
-	g.populateCache(key, value)
+	value := ByteView{b: cloneBytes(bytes), e: expireAfter(g.ttl), ver: ver}
+	g.populateCache(key, value, g.mainCache)
 	return value, nil
 }
 
-func (g *Group) populateCache(key string, value ByteView) {
-	g.mainCache.add(key, value)
+// populateCache 写入缓存，缓存项过期后在 staleTTL 内仍然保留，以便返回旧值
+// 开启压缩时较大的值压缩后存储，调用方持有的 value 不受影响；
+// 在 Invalidate、Set、Remove 之前开始加载的值已经过时，不再写入
+func (g *Group) populateCache(key string, value ByteView, cache *shardedCache) {
+	if g.outdated(key, value.ver) {
+		return
+	}
+	value = g.compress(value)
+	expire := value.e
+	if !expire.IsZero() && !value.notFound {
+		expire = expire.Add(g.staleTTL)
+	}
+	// 在分片的锁内再检查一次：Set、Remove 先递增写序号再写入或删除，
+	// 检查通过后写入的旧值一定会被随后的删除或写入覆盖
+	cache.addIf(key, value, expire, func() bool { return !g.outdated(key, value.ver) })
+}
+
+// writeSeq 返回 key 所在分段的写序号
+func (g *Group) writeSeq(key string) *atomic.Uint64 {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return &g.writeSeqs[h.Sum32()%writeSeqStripes]
+}
+
+// version 返回 key 当前的版本，在开始加载前获取
+func (g *Group) version(key string) version {
+	return version{gen: g.generation.Load(), seq: g.writeSeq(key).Load()}
+}
+
+// outdated 判断以版本 ver 加载的值是否已经过时，Group 注销后的所有值都视为过时
+func (g *Group) outdated(key string, ver version) bool {
+	return ver.gen < g.generation.Load() || ver.seq < g.writeSeq(key).Load() || g.ctx.Err() != nil
+}
+
+// expireAfter 根据存活时间计算过期时间，ttl <= 0 表示永不过期
+func expireAfter(ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(ttl)
+}
+
+// CacheType 表示 Group 内部的缓存类型
+type CacheType int
+
+const (
+	// MainCache 本节点负责的 key
+	MainCache CacheType = iota + 1
+	// HotCache 其他节点负责的热点 key 副本
+	HotCache
+)
+
+// CacheStats 返回指定缓存的统计信息
+func (g *Group) CacheStats(which CacheType) CacheStats {
+	switch which {
+	case MainCache:
+		return g.mainCache.stats()
+	case HotCache:
+		return g.hotCache.stats()
+	default:
+		return CacheStats{}
+	}
+}
+
+// Set 直接写入 key 对应的缓存，不经过 Getter 回源
+// 分布式场景下，若 key 归属其他节点，会先写入该节点，本地只在 hotCache 中保留存活时间有限的副本；
+// 归属节点不可达时返回错误，不会写入本地
+func (g *Group) Set(key string, value []byte) error {
+	if key == "" {
+		return nil
+	}
+
+	if g.peer != nil {
+		if peer, ok := pickOwner(g.peer, key); ok {
+			req := &pb.Request{Group: g.name, Key: key, Value: value, TTL: g.ttl, Gen: g.Generation()}
+			if err := peer.Set(context.Background(), req); err != nil {
+				return err
+			}
+			g.setReplica(key, value)
+			return nil
+		}
+	}
+	g.setLocally(key, value, g.ttl)
+	return nil
+}
+
+// setReplica 为归属其他节点的 key 写入副本，同时丢弃本地 mainCache 中的旧值
+// 归属节点之后的 Remove 不会通知本节点，副本按 replicaTTL 过期
+func (g *Group) setReplica(key string, value []byte) {
+	ver := version{gen: g.generation.Load(), seq: g.writeSeq(key).Add(1)}
+	g.mainCache.remove(key)
+	g.populateCache(key, ByteView{b: cloneBytes(value), e: expireAfter(replicaTTL(g.ttl)), ver: ver}, g.hotCache)
+}
+
+// setLocally 只写入本地缓存，供处理远程节点的写入请求使用
+// 递增写序号，使进行中的加载不会用旧值覆盖写入的值
+func (g *Group) setLocally(key string, value []byte, ttl time.Duration) {
+	ver := version{gen: g.generation.Load(), seq: g.writeSeq(key).Add(1)}
+	g.populateCache(key, ByteView{b: cloneBytes(value), e: expireAfter(ttl), ver: ver}, g.mainCache)
+}
+
+// Remove 删除 key 对应的缓存
+// 分布式场景下，若 key 归属其他节点，会先通知该节点删除，再删除本地副本；
+// 归属节点不可达时返回错误
+func (g *Group) Remove(key string) error {
+	if key == "" {
+		return nil
+	}
+
+	if g.peer != nil {
+		if peer, ok := pickOwner(g.peer, key); ok {
+			if err := peer.Remove(context.Background(), &pb.Request{Group: g.name, Key: key, Gen: g.Generation()}); err != nil {
+				return err
+			}
+		}
+	}
+	g.removeLocally(key)
+	return nil
+}
+
+// removeLocally 只删除本地缓存，供处理远程节点的删除请求使用
+// 先递增写序号，删除前发起、尚未完成的加载不会再把旧值写回缓存；
+// 同时遗忘进行中的加载，之后的 Get 重新加载，而不是等待并得到删除前的旧值
+func (g *Group) removeLocally(key string) {
+	g.writeSeq(key).Add(1)
+	g.loader.Forget(key)
+	g.mainCache.remove(key)
+	g.hotCache.remove(key)
+}
+
+// Purge 丢弃本节点上该 Group 的所有缓存（mainCache 与 hotCache），不影响其他节点
+func (g *Group) Purge() {
+	g.mainCache.purge()
+	g.hotCache.purge()
+}
+
+// Name 返回 Group 的名称
+func (g *Group) Name() string {
+	return g.name
 }
 
 // RegisterPeers 注册一个实现了 PeerPicker 接口的 HTTPPool
@@ -126,12 +575,90 @@ func (g *Group) RegisterPeers(peers PeerPicker) {
 }
 
 // getFromPeer 从对应节点获取缓存值
-func (g *Group) getFromPeer(peer PeerGetter, key string) (ByteView, error) {
-	bytes, err := peer.Get(g.name, key)
+func (g *Group) getFromPeer(ctx context.Context, peer PeerGetter, key string, ver version) (ByteView, error) {
+	req := &pb.Request{Group: g.name, Key: key, Gen: g.Generation()}
+	res := &pb.Response{}
+	err := peer.Get(ctx, req, res)
+	g.advanceGeneration(res.Gen)
+	return g.fromPeer(key, res, err, ver)
+}
+
+// fromPeer 将远程节点的响应转换为 ByteView，并按需写入 hotCache，ver 为开始加载时的版本
+func (g *Group) fromPeer(key string, res *pb.Response, err error, ver version) (ByteView, error) {
 	if err != nil {
-		return ByteView{}, err
+		var pe *pb.Error
+		if !errors.As(err, &pe) || pe.Code != pb.CodeKeyNotFound {
+			return ByteView{}, err
+		}
+		// 归属节点确认 key 不存在，按其给出的时间缓存负结果，负缓存项很小，不必抽样
+		if res.TTL > 0 {
+			g.populateCache(key, ByteView{e: expireAfter(replicaTTL(res.TTL)), notFound: true, ver: ver}, g.hotCache)
+		} else {
+			g.hotCache.remove(key)
+		}
+		return ByteView{}, fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+
+	// 沿用归属节点上的剩余存活时间，避免副本比原值活得更久
+	value := ByteView{b: res.Value, e: expireAfter(res.TTL), ver: ver}
+	// 抽样放入 hotCache，访问越频繁的 key 越容易被抽中；
+	// 副本不会收到归属节点上的删除，存活时间不超过 maxReplicaTTL
+	if rand.Intn(hotCacheRatio) == 0 {
+		replica := value
+		replica.e = expireAfter(replicaTTL(res.TTL))
+		g.populateCache(key, replica, g.hotCache)
+	}
+	return value, nil
+}
+
+// getResponse 处理其他节点的读请求，gen 为请求方的代数，先推进本地代数再查找
+func (g *Group) getResponse(ctx context.Context, key string, gen uint64) *pb.Response {
+	res, view := g.lookupResponse(ctx, key, gen)
+	if view.compressed {
+		res.Value = view.uncompressed()
 	}
+	return res
+}
 
-	return ByteView{b: bytes}, nil
+// lookupResponse 与 getResponse 相同，但命中压缩存储的值时不解压：此时 res.Value 为空，
+// view 为压缩存储的值，由调用方决定原样发送压缩数据还是解压
+func (g *Group) lookupResponse(ctx context.Context, key string, gen uint64) (*pb.Response, ByteView) {
+	g.advanceGeneration(gen)
+	view, err := g.lookup(ctx, key)
+	var res pb.Response
+	if err == nil && view.compressed {
+		res = pb.Response{TTL: view.ttl()}
+	} else {
+		res = g.response(key, view, err)
+		view = ByteView{}
+	}
+	res.Gen = g.Generation()
+	return &res, view
+}
 
+// getMultiResponse 处理其他节点的批量读请求，gen 同 getResponse
+func (g *Group) getMultiResponse(ctx context.Context, keys []string, gen uint64) *pb.BatchResponse {
+	g.advanceGeneration(gen)
+	results := g.lookupMulti(ctx, keys)
+	res := &pb.BatchResponse{Responses: make([]pb.Response, len(keys)), Gen: g.Generation()}
+	for i, key := range keys {
+		view, _ := results[key].Val.(ByteView)
+		res.Responses[i] = g.response(key, view, results[key].Err)
+	}
+	return res
+}
+
+// response 将查找结果编码为响应
+// key 不存在时返回 CodeKeyNotFound，TTL 为负缓存剩余的存活时间，使请求方也能缓存该结果
+func (g *Group) response(key string, view ByteView, err error) pb.Response {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return pb.Response{Code: pb.CodeKeyNotFound, Error: err.Error(), TTL: g.negativeTTL}
+	case err != nil:
+		return pb.Response{Code: pb.CodeInternal, Error: err.Error()}
+	case view.notFound:
+		return pb.Response{Code: pb.CodeKeyNotFound, Error: fmt.Sprintf("%v: %s", ErrNotFound, key), TTL: view.ttl()}
+	}
+	// ByteView.ByteSlice() 会生成一个新的拷贝，避免共享底层数组
+	return pb.Response{Value: view.ByteSlice(), TTL: view.ttl()}
 }
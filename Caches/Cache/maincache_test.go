@@ -3,10 +3,16 @@
 package cache
 
 import (
+	pb "cache/cachepb"
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"reflect"
+	"runtime"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestGetter(t *testing.T) {
@@ -20,6 +26,106 @@ func TestGetter(t *testing.T) {
 	}
 }
 
+// newTestGroup 创建容量为 cacheBytes 的 Group，测试结束后销毁，使同名 Group 可以在 -count 下重复创建
+func newTestGroup(t *testing.T, name string, cacheBytes int64, getter Getter, opts ...GroupOption) *Group {
+	t.Helper()
+	g, err := NewGroup(name, getter, append([]GroupOption{WithCacheBytes(cacheBytes)}, opts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { DestroyGroup(name) })
+	return g
+}
+
+func TestNewGroup(t *testing.T) {
+	getter := GetterFunc(func(key string) ([]byte, error) {
+		return []byte(key), nil
+	})
+	peers := &fakePeers{}
+	g, err := NewGroup("lifecycle", getter,
+		WithCacheBytes(2<<10), WithTTL(time.Minute), WithPolicy(LFU), WithPeers(peers),
+		WithNegativeTTL(time.Second), WithStaleWhileRevalidate(2*time.Second),
+		WithRefreshAhead(3*time.Second), WithCompression(256))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.ttl != time.Minute || g.peer != peers || GetGroup("lifecycle") != g {
+		t.Fatalf("options not applied: ttl %v peer %v", g.ttl, g.peer)
+	}
+	if g.negativeTTL != time.Second || g.staleTTL != 2*time.Second || g.refreshAhead != 3*time.Second || g.compressThreshold != 256 {
+		t.Fatalf("options not applied: %v %v %v %d", g.negativeTTL, g.staleTTL, g.refreshAhead, g.compressThreshold)
+	}
+	if g.mainCache.shards[0].policy != LFU || g.hotCache.shards[0].policy != LFU {
+		t.Fatalf("WithPolicy(LFU) should use an LFU store")
+	}
+
+	// 同名 Group 不会被替换
+	if _, err := NewGroup("lifecycle", getter); !errors.Is(err, ErrGroupExists) {
+		t.Fatalf("duplicate NewGroup err = %v, want ErrGroupExists", err)
+	}
+	if GetGroup("lifecycle") != g {
+		t.Fatalf("duplicate NewGroup should keep the existing group")
+	}
+
+	g.setLocally("jw", []byte("114"), 0)
+	g.StartSnapshots(t.TempDir()+"/snapshot", time.Hour)
+	if !DestroyGroup("lifecycle") || GetGroup("lifecycle") != nil {
+		t.Fatalf("DestroyGroup should unregister the group")
+	}
+	if g.snapshotStop != nil || g.CacheStats(MainCache).Items != 0 {
+		t.Fatalf("DestroyGroup should stop snapshots and drop cached entries")
+	}
+	if DestroyGroup("lifecycle") {
+		t.Fatalf("destroying a missing group should return false")
+	}
+
+	// 销毁后可以用新的配置重新创建
+	g2, err := NewGroup("lifecycle", getter, WithCacheBytes(4<<10))
+	if err != nil || g2 == g {
+		t.Fatalf("NewGroup after DestroyGroup = %v, %v", g2, err)
+	}
+	DestroyGroup("lifecycle")
+}
+
+// TestDestroyGroupStopsRefresh DestroyGroup 取消进行中的后台刷新，刷新结果不会写回已注销的 Group
+func TestDestroyGroupStopsRefresh(t *testing.T) {
+	var loads atomic.Int32
+	canceled := make(chan struct{})
+	g, err := NewGroup("destroy-refresh", ContextGetterFunc(
+		func(ctx context.Context, key string) ([]byte, error) {
+			if loads.Add(1) == 1 {
+				return []byte("v1"), nil
+			}
+			<-ctx.Done()
+			close(canceled)
+			return []byte("v2"), nil
+		}), WithCacheBytes(2<<10), WithTTL(10*time.Millisecond), WithStaleWhileRevalidate(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g.Get("jw")
+	time.Sleep(20 * time.Millisecond)
+	g.Get("jw") // 过期后命中，启动后台刷新
+	waitLoads(t, &loads, 2)
+
+	DestroyGroup("destroy-refresh")
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatalf("refresh should be canceled by DestroyGroup")
+	}
+	time.Sleep(10 * time.Millisecond)
+	if s := g.CacheStats(MainCache); s.Items != 0 {
+		t.Fatalf("refresh should not repopulate a destroyed group, %d items", s.Items)
+	}
+	// 注销后不再启动新的刷新
+	g.refresh("jw", g.mainCache)
+	if _, busy := g.refreshing.Load("jw"); busy {
+		t.Fatalf("destroyed group should not start refreshes")
+	}
+}
+
 var db = map[string]string{
 	"jw":    "114",
 	"boyue": "514",
@@ -28,7 +134,7 @@ var db = map[string]string{
 
 func TestGet(t *testing.T) {
 	loadCounts := make(map[string]int, len(db))
-	tmp := NewGroup("test", 2<<10, GetterFunc(
+	tmp := newTestGroup(t, "test", 2<<10, GetterFunc(
 		func(key string) ([]byte, error) {
 			log.Println("[SlowDB] search key", key)
 			if v, ok := db[key]; ok {
@@ -55,3 +161,490 @@ func TestGet(t *testing.T) {
 		t.Fatalf("the value of unknown should be nil, but %s got", view)
 	}
 }
+
+func TestGetWithTTL(t *testing.T) {
+	loads := 0
+	g := newTestGroup(t, "ttl", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			loads++
+			return []byte(key), nil
+		}), WithTTL(20*time.Millisecond))
+
+	if _, err := g.Get("jw"); err != nil || loads != 1 {
+		t.Fatalf("first get should load once, loads %d", loads)
+	}
+	if _, err := g.Get("jw"); err != nil || loads != 1 {
+		t.Fatalf("second get should hit cache, loads %d", loads)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if view, err := g.Get("jw"); err != nil || view.String() != "jw" || loads != 2 {
+		t.Fatalf("expired key should reload, loads %d", loads)
+	}
+}
+
+// fakePeers 将所有 key 路由到同一个假节点，记录收到的请求
+type fakePeers struct {
+	values  map[string]string
+	gets    int
+	removed []string
+	set     map[string]string
+	// negativeTTL 不为 0 时，不存在的 key 返回 CodeKeyNotFound
+	negativeTTL time.Duration
+}
+
+func (f *fakePeers) PickPeer(key string) (PeerGetter, bool) {
+	return f, true
+}
+
+func (f *fakePeers) Get(_ context.Context, in *pb.Request, out *pb.Response) error {
+	f.gets++
+	if v, ok := f.values[in.Key]; ok {
+		out.Value = []byte(v)
+		return nil
+	}
+	if f.negativeTTL > 0 {
+		*out = pb.Response{Code: pb.CodeKeyNotFound, Error: in.Key + " not exist", TTL: f.negativeTTL}
+		return out.Err()
+	}
+	return fmt.Errorf("%s not exist", in.Key)
+}
+
+func (f *fakePeers) Remove(_ context.Context, in *pb.Request) error {
+	f.removed = append(f.removed, in.Group+"/"+in.Key)
+	return nil
+}
+
+func (f *fakePeers) Set(_ context.Context, in *pb.Request) error {
+	if f.set == nil {
+		f.set = make(map[string]string)
+	}
+	f.set[in.Group+"/"+in.Key] = string(in.Value)
+	return nil
+}
+
+func TestRemove(t *testing.T) {
+	loads := 0
+	g := newTestGroup(t, "remove", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			loads++
+			return []byte(key), nil
+		}))
+	peers := &fakePeers{}
+	g.RegisterPeers(peers)
+
+	g.Get("jw")
+	if err := g.Remove("jw"); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	if !reflect.DeepEqual(peers.removed, []string{"remove/jw"}) {
+		t.Fatalf("Remove should be routed to owner peer, got %v", peers.removed)
+	}
+
+	g.Get("jw")
+	if loads != 2 {
+		t.Fatalf("removed key should reload, loads %d", loads)
+	}
+}
+
+// TestWriteDuringLoad Set、Remove 与进行中的加载并发时，加载完成后不会用旧值覆盖
+func TestWriteDuringLoad(t *testing.T) {
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	g := newTestGroup(t, "write-during-load", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			started <- struct{}{}
+			<-release
+			return []byte("old"), nil
+		}))
+
+	for _, write := range []func(){
+		func() { g.Remove("k") },
+		func() { g.Set("k", []byte("new")) },
+	} {
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			g.Get("k")
+		}()
+		<-started
+		write()
+		release <- struct{}{}
+		<-done
+
+		if v, ok := g.mainCache.get("k"); ok && v.String() == "old" {
+			t.Fatalf("load started before the write should not be cached")
+		}
+		g.removeLocally("k")
+	}
+}
+
+func TestSet(t *testing.T) {
+	loads := 0
+	g := newTestGroup(t, "set", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			loads++
+			return nil, fmt.Errorf("%s not exist", key)
+		}))
+	peers := &fakePeers{}
+	g.RegisterPeers(peers)
+
+	if err := g.Set("jw", []byte("114")); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if peers.set["set/jw"] != "114" {
+		t.Fatalf("Set should be written to owner peer, got %v", peers.set)
+	}
+	if view, err := g.Get("jw"); err != nil || view.String() != "114" || loads != 0 {
+		t.Fatalf("Get after Set should hit cache, got %q loads %d", view, loads)
+	}
+	// 归属其他节点的 key 只保留存活时间有限的副本，不写入 mainCache
+	if _, ok := g.mainCache.get("jw"); ok {
+		t.Fatalf("peer-owned key should not be written into main cache")
+	}
+	if v, ok := g.hotCache.peek("jw"); !ok || v.e.IsZero() || time.Until(v.e) > maxReplicaTTL {
+		t.Fatalf("replica should expire within maxReplicaTTL, got %v", v.e)
+	}
+}
+
+func TestHotCache(t *testing.T) {
+	g := newTestGroup(t, "hot", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			return nil, fmt.Errorf("%s not exist", key)
+		}))
+	peers := &fakePeers{values: db}
+	g.RegisterPeers(peers)
+
+	for i := 0; i < 1000; i++ {
+		if view, err := g.Get("jw"); err != nil || view.String() != "114" {
+			t.Fatalf("failed to get jw from peer")
+		}
+	}
+
+	if peers.gets >= 1000 {
+		t.Fatalf("hot key should be served locally, peer gets %d", peers.gets)
+	}
+	if stats := g.CacheStats(HotCache); stats.Items != 1 || stats.Hits != int64(1000-peers.gets) {
+		t.Fatalf("unexpected hot cache stats %+v, peer gets %d", stats, peers.gets)
+	}
+	if stats := g.CacheStats(MainCache); stats.Items != 0 {
+		t.Fatalf("peer-owned key should not be in main cache, got %+v", stats)
+	}
+	// 原值永不过期时，副本也按 maxReplicaTTL 过期
+	if v, ok := g.hotCache.peek("jw"); !ok || v.e.IsZero() || time.Until(v.e) > maxReplicaTTL {
+		t.Fatalf("replica should expire within maxReplicaTTL, got %v", v.e)
+	}
+}
+
+func TestHotCacheBytes(t *testing.T) {
+	for cacheBytes, want := range map[int64]int64{0: 0, 4: 1, 8 << 10: 1 << 10} {
+		if got := hotCacheBytes(cacheBytes); got != want {
+			t.Fatalf("hotCacheBytes(%d) = %d, want %d", cacheBytes, got, want)
+		}
+	}
+}
+
+func TestStats(t *testing.T) {
+	g := newTestGroup(t, "stats", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			if v, ok := db[key]; ok {
+				return []byte(v), nil
+			}
+			return nil, fmt.Errorf("%s not exist", key)
+		}))
+
+	g.Get("jw")
+	g.Get("jw")
+	g.Get("unknown")
+
+	s := g.Stats()
+	if s.Gets != 3 || s.Hits != 1 || s.Loads != 2 || s.LocalLoads != 1 || s.LocalLoadErrs != 1 {
+		t.Fatalf("unexpected stats %+v", s)
+	}
+	if s.Items != 1 || s.Bytes != int64(len("jw")+len("114")) {
+		t.Fatalf("unexpected cache usage %+v", s)
+	}
+}
+
+func TestGetContext(t *testing.T) {
+	release := make(chan struct{})
+	g := newTestGroup(t, "context", 2<<10, ContextGetterFunc(
+		func(ctx context.Context, key string) ([]byte, error) {
+			select {
+			case <-release:
+				return []byte(key), nil
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}))
+
+	// ctx 超时后立即返回，加载仍在后台进行
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := g.GetContext(ctx, "jw"); err != context.DeadlineExceeded {
+		t.Fatalf("expect DeadlineExceeded, got %v", err)
+	}
+
+	// 等待者单独放弃，不影响正在进行的加载
+	done := make(chan error, 1)
+	go func() {
+		_, err := g.GetContext(context.Background(), "Sam")
+		done <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	waiter, cancelWaiter := context.WithCancel(context.Background())
+	cancelWaiter()
+	if _, err := g.GetContext(waiter, "Sam"); err != context.Canceled {
+		t.Fatalf("expect Canceled, got %v", err)
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("leader should finish loading, got %v", err)
+	}
+}
+
+// TestLoadDetached 发起加载的请求取消后，合并到同一加载的其他请求仍能取得结果
+func TestLoadDetached(t *testing.T) {
+	var loads atomic.Int32
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	g := newTestGroup(t, "detached", 2<<10, ContextGetterFunc(
+		func(ctx context.Context, key string) ([]byte, error) {
+			loads.Add(1)
+			started <- struct{}{}
+			select {
+			case <-release:
+			case <-ctx.Done():
+			}
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			return []byte(key), nil
+		}), WithLoadTimeout(time.Second))
+
+	leader, cancel := context.WithCancel(context.Background())
+	leaderErr := make(chan error, 1)
+	go func() {
+		_, err := g.GetContext(leader, "jw")
+		leaderErr <- err
+	}()
+	<-started
+
+	follower := make(chan error, 1)
+	go func() {
+		v, err := g.Get("jw")
+		if err == nil && v.String() != "jw" {
+			err = fmt.Errorf("got %q", v)
+		}
+		follower <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	if err := <-leaderErr; err != context.Canceled {
+		t.Fatalf("leader expect Canceled, got %v", err)
+	}
+	close(release)
+	if err := <-follower; err != nil {
+		t.Fatalf("follower should get the value, got %v", err)
+	}
+	if n := loads.Load(); n != 1 {
+		t.Fatalf("loads = %d, want 1", n)
+	}
+
+	// 加载超过 loadTimeout 后取消
+	g2 := newTestGroup(t, "load-timeout", 2<<10, ContextGetterFunc(
+		func(ctx context.Context, key string) ([]byte, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}), WithLoadTimeout(10*time.Millisecond))
+	if _, err := g2.Get("jw"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expect DeadlineExceeded, got %v", err)
+	}
+}
+
+// TestLoadPanic Getter panic 时返回错误，而不是使进程崩溃
+func TestLoadPanic(t *testing.T) {
+	g := newTestGroup(t, "panic", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			panic("boom")
+		}))
+
+	if _, err := g.Get("jw"); !errors.Is(err, ErrLoadPanic) {
+		t.Fatalf("Get err = %v, want ErrLoadPanic", err)
+	}
+	if _, err := g.GetMulti([]string{"jw", "Sam"}); !errors.Is(err, ErrLoadPanic) {
+		t.Fatalf("GetMulti err = %v, want ErrLoadPanic", err)
+	}
+}
+
+func TestNegativeCache(t *testing.T) {
+	loads := 0
+	g := newTestGroup(t, "negative", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			loads++
+			if key == "broken" {
+				return nil, errors.New("db unavailable")
+			}
+			return nil, fmt.Errorf("%s not exist: %w", key, ErrNotFound)
+		}), WithNegativeTTL(20*time.Millisecond))
+
+	for i := 0; i < 3; i++ {
+		if _, err := g.Get("unknown"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("expect ErrNotFound, got %v", err)
+		}
+	}
+	if loads != 1 {
+		t.Fatalf("not found result should be cached, loads %d", loads)
+	}
+	time.Sleep(30 * time.Millisecond)
+	g.Get("unknown")
+	if loads != 2 {
+		t.Fatalf("negative entry should expire, loads %d", loads)
+	}
+
+	// 其他错误不缓存
+	g.Get("broken")
+	g.Get("broken")
+	if loads != 4 {
+		t.Fatalf("other errors should not be cached, loads %d", loads)
+	}
+}
+
+func TestPeerNegativeCache(t *testing.T) {
+	loads := 0
+	g := newTestGroup(t, "peer-negative", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			loads++
+			return []byte(key), nil
+		}))
+	peers := &fakePeers{negativeTTL: time.Minute}
+	g.RegisterPeers(peers)
+
+	for i := 0; i < 3; i++ {
+		if _, err := g.Get("unknown"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("expect ErrNotFound, got %v", err)
+		}
+	}
+	if loads != 0 {
+		t.Fatalf("peer not found should not fall back to local getter, loads %d", loads)
+	}
+	if peers.gets != 1 {
+		t.Fatalf("peer not found should be cached locally, peer gets %d", peers.gets)
+	}
+}
+
+// versionedGetter 每次加载返回递增的版本号
+func versionedGetter(loads *atomic.Int32) Getter {
+	return GetterFunc(func(key string) ([]byte, error) {
+		return []byte(fmt.Sprintf("v%d", loads.Add(1))), nil
+	})
+}
+
+// waitLoads 等待后台加载完成
+func waitLoads(t *testing.T, loads *atomic.Int32, want int32) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for loads.Load() < want {
+		if time.Now().After(deadline) {
+			t.Fatalf("loads %d, want %d", loads.Load(), want)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	// 加载完成后还需写入缓存
+	time.Sleep(5 * time.Millisecond)
+}
+
+func TestStaleWhileRevalidate(t *testing.T) {
+	var loads atomic.Int32
+	g := newTestGroup(t, "stale", 2<<10, versionedGetter(&loads),
+		WithTTL(20*time.Millisecond), WithStaleWhileRevalidate(time.Hour))
+
+	if v, _ := g.Get("jw"); v.String() != "v1" {
+		t.Fatalf("first get = %q", v)
+	}
+	time.Sleep(30 * time.Millisecond)
+
+	// 过期后立即返回旧值，并在后台重新加载
+	if v, err := g.Get("jw"); err != nil || v.String() != "v1" {
+		t.Fatalf("expired get should return stale value, got %q err %v", v, err)
+	}
+	waitLoads(t, &loads, 2)
+	if v, _ := g.Get("jw"); v.String() != "v2" {
+		t.Fatalf("get after refresh = %q, want v2", v)
+	}
+	if s := g.Stats(); s.StaleHits != 1 || s.Refreshes != 1 {
+		t.Fatalf("unexpected stats %+v", s)
+	}
+}
+
+// TestRefreshDedup 刷新未完成前的多次命中只启动一个后台协程
+func TestRefreshDedup(t *testing.T) {
+	var loads atomic.Int32
+	release := make(chan struct{})
+	g := newTestGroup(t, "refresh-dedup", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			if loads.Add(1) > 1 {
+				<-release
+			}
+			return []byte(key), nil
+		}), WithTTL(10*time.Millisecond), WithStaleWhileRevalidate(time.Hour))
+
+	g.Get("jw")
+	time.Sleep(20 * time.Millisecond)
+	before := runtime.NumGoroutine()
+	for i := 0; i < 100; i++ {
+		if v, err := g.Get("jw"); err != nil || v.String() != "jw" {
+			t.Fatalf("stale get = %q, err %v", v, err)
+		}
+	}
+	if n := runtime.NumGoroutine() - before; n > 10 {
+		t.Fatalf("%d goroutines started for one refresh", n)
+	}
+	close(release)
+	waitLoads(t, &loads, 2)
+}
+
+// TestRefreshReplicaTTL 刷新 hotCache 中的副本时同样限制其存活时间，归属节点上永不过期的值也不例外
+func TestRefreshReplicaTTL(t *testing.T) {
+	g := newTestGroup(t, "refresh-replica", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			return []byte(key), nil
+		}))
+	g.RegisterPeers(&fakePeers{values: map[string]string{"jw": "remote"}})
+
+	g.refresh("jw", g.hotCache)
+	deadline := time.Now().Add(time.Second)
+	for {
+		if v, ok := g.hotCache.get("jw"); ok {
+			if v.String() != "remote" || v.Expire().IsZero() || time.Until(v.Expire()) > maxReplicaTTL {
+				t.Fatalf("refreshed replica = %q, expire in %v", v, time.Until(v.Expire()))
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("refresh did not populate hotCache")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestRefreshAhead(t *testing.T) {
+	var loads atomic.Int32
+	g := newTestGroup(t, "refresh-ahead", 2<<10, versionedGetter(&loads),
+		WithTTL(100*time.Millisecond), WithRefreshAhead(60*time.Millisecond))
+
+	g.Get("jw")
+	if v, _ := g.Get("jw"); v.String() != "v1" || loads.Load() != 1 {
+		t.Fatalf("fresh hit should not refresh, got %q loads %d", v, loads.Load())
+	}
+
+	// 剩余存活时间进入窗口后，命中触发后台刷新，调用方仍立即得到当前值
+	time.Sleep(50 * time.Millisecond)
+	if v, _ := g.Get("jw"); v.String() != "v1" {
+		t.Fatalf("get in refresh window = %q, want v1", v)
+	}
+	waitLoads(t, &loads, 2)
+	if v, _ := g.Get("jw"); v.String() != "v2" || time.Until(v.Expire()) <= 60*time.Millisecond {
+		t.Fatalf("refreshed value = %q, expire in %v", v, time.Until(v.Expire()))
+	}
+}
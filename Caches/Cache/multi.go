@@ -0,0 +1,251 @@
+package cache
+
+import (
+	pb "cache/cachepb"
+	"cache/singleflight"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+)
+
+// GetMulti 批量获取多个 key，返回存在的 key 及其值
+func (g *Group) GetMulti(keys []string) (map[string]ByteView, error) {
+	return g.GetMultiContext(context.Background(), keys)
+}
+
+// GetMultiContext 批量获取多个 key：
+// - 先逐个查找 mainCache 和 hotCache
+// - 未命中的 key 按归属节点分组，每个节点只发一次批量请求
+// - 本节点负责的 key 以及节点请求失败的 key 合并为一次回源，Getter 实现 BatchGetter 时只调用一次
+// - 每个 key 仍经过 singleflight，与同时进行的 Get 共享加载结果
+//
+// 不存在的 key 不出现在结果中；其他错误时返回已获取到的结果及第一个错误（按 keys 的顺序）
+func (g *Group) GetMultiContext(ctx context.Context, keys []string) (map[string]ByteView, error) {
+	results := g.lookupMulti(ctx, keys)
+	values := make(map[string]ByteView, len(results))
+	var firstErr error
+	for _, key := range keys {
+		r, ok := results[key]
+		if !ok {
+			continue
+		}
+		view, _ := r.Val.(ByteView)
+		switch {
+		case errors.Is(r.Err, ErrNotFound), r.Err == nil && view.notFound:
+		case r.Err != nil:
+			if firstErr == nil {
+				firstErr = r.Err
+			}
+		default:
+			values[key] = view
+		}
+	}
+	return values, firstErr
+}
+
+// lookupMulti 批量查找，每个 key 的结果同 lookup，重复的 key 只查找一次
+func (g *Group) lookupMulti(ctx context.Context, keys []string) map[string]singleflight.Result {
+	results := make(map[string]singleflight.Result, len(keys))
+	var misses []string
+	for _, key := range keys {
+		if _, ok := results[key]; ok {
+			continue
+		}
+		g.stats.gets.Add(1)
+		if key == "" {
+			results[key] = singleflight.Result{Val: ByteView{}}
+			continue
+		}
+		if v, ok := g.getCached(key); ok {
+			results[key] = singleflight.Result{Val: v}
+			continue
+		}
+		// 先占位，避免重复的 key 再次查找
+		results[key] = singleflight.Result{}
+		misses = append(misses, key)
+	}
+
+	if len(misses) > 0 {
+		for key, r := range g.loadMulti(ctx, misses) {
+			results[key] = r
+		}
+	}
+	return results
+}
+
+// loadMulti 批量加载，与 load 一样加载不随 ctx 取消，每个调用方可以通过 ctx 单独放弃等待
+func (g *Group) loadMulti(ctx context.Context, keys []string) map[string]singleflight.Result {
+	g.stats.loads.Add(int64(len(keys)))
+
+	done := make(chan map[string]singleflight.Result, 1)
+	go func() {
+		// executed 为实际由当前协程加载的 key 数量，其余的 key 被 singleflight 合并
+		executed := 0
+		results := g.loader.DoMulti(keys, func(keys []string) (results map[string]singleflight.Result) {
+			executed = len(keys)
+			defer func() {
+				if r := recover(); r != nil {
+					err := loadPanicError(strings.Join(keys, ","), r)
+					results = make(map[string]singleflight.Result, len(keys))
+					for _, key := range keys {
+						results[key] = singleflight.Result{Err: err}
+					}
+				}
+			}()
+			loadCtx, cancel := g.loadContext(ctx)
+			defer cancel()
+			return g.loadMultiOnce(loadCtx, keys)
+		})
+		g.stats.loadsDeduped.Add(int64(len(keys) - executed))
+		done <- results
+	}()
+
+	select {
+	case results := <-done:
+		return results
+	case <-ctx.Done():
+		results := make(map[string]singleflight.Result, len(keys))
+		for _, key := range keys {
+			results[key] = singleflight.Result{Err: ctx.Err()}
+		}
+		return results
+	}
+}
+
+// loadMultiOnce 与 loadOnce 相同，但按节点批量加载
+func (g *Group) loadMultiOnce(ctx context.Context, keys []string) map[string]singleflight.Result {
+	vers := make(map[string]version, len(keys))
+	for _, key := range keys {
+		vers[key] = g.version(key)
+	}
+	results := make(map[string]singleflight.Result, len(keys))
+	local := keys
+	if g.peer != nil {
+		local = nil
+		// 按主节点归并，发送时使用第一个带副本的 PeerGetter，主节点故障时整批在该副本上重试
+		byPeer := make(map[PeerGetter]*peerBatch)
+		for _, key := range keys {
+			peer, ok := g.peer.PickPeer(key)
+			if !ok {
+				local = append(local, key)
+				continue
+			}
+			b := byPeer[primaryPeer(peer)]
+			if b == nil {
+				b = &peerBatch{peer: peer}
+				byPeer[primaryPeer(peer)] = b
+			} else if b.peer == primaryPeer(b.peer) {
+				b.peer = peer
+			}
+			b.keys = append(b.keys, key)
+		}
+
+		// 各节点的请求并发进行
+		var mu sync.Mutex
+		var wg sync.WaitGroup
+		for _, b := range byPeer {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				keys := b.keys
+				fetched := g.getMultiFromPeer(ctx, b.peer, keys, vers)
+
+				mu.Lock()
+				defer mu.Unlock()
+				for _, key := range keys {
+					r := fetched[key]
+					if r.Err == nil || errors.Is(r.Err, ErrNotFound) {
+						g.stats.peerLoads.Add(1)
+						results[key] = r
+						continue
+					}
+					g.stats.peerErrors.Add(1)
+					log.Println("[Cache] Failed to get from peer", r.Err)
+					// 调用方已放弃，不必再回源
+					if ctx.Err() != nil {
+						results[key] = singleflight.Result{Err: ctx.Err()}
+						continue
+					}
+					local = append(local, key)
+				}
+			}()
+		}
+		wg.Wait()
+	}
+
+	for key, r := range g.getLocallyMulti(ctx, local, vers) {
+		if r.Err != nil && !errors.Is(r.Err, ErrNotFound) {
+			g.stats.localLoadErrs.Add(1)
+		} else {
+			g.stats.localLoads.Add(1)
+		}
+		results[key] = r
+	}
+	return results
+}
+
+// peerBatch 发往同一主节点的 key
+type peerBatch struct {
+	peer PeerGetter
+	keys []string
+}
+
+// getMultiFromPeer 向节点发起一次批量请求，请求整体失败时所有 key 都返回该错误
+func (g *Group) getMultiFromPeer(ctx context.Context, peer PeerGetter, keys []string, vers map[string]version) map[string]singleflight.Result {
+	req := &pb.BatchRequest{Group: g.name, Keys: keys, Gen: g.Generation()}
+	res := &pb.BatchResponse{}
+	err := getMulti(ctx, peer, req, res)
+	g.advanceGeneration(res.Gen)
+	if err == nil && len(res.Responses) != len(keys) {
+		err = fmt.Errorf("peer returned %d responses for %d keys", len(res.Responses), len(keys))
+	}
+
+	results := make(map[string]singleflight.Result, len(keys))
+	for i, key := range keys {
+		if err != nil {
+			results[key] = singleflight.Result{Err: err}
+			continue
+		}
+		view, err := g.fromPeer(key, &res.Responses[i], res.Responses[i].Err(), vers[key])
+		results[key] = singleflight.Result{Val: view, Err: err}
+	}
+	return results
+}
+
+// getLocallyMulti 批量回源，Getter 实现 BatchGetter 时只调用一次 GetMulti
+func (g *Group) getLocallyMulti(ctx context.Context, keys []string, vers map[string]version) map[string]singleflight.Result {
+	results := make(map[string]singleflight.Result, len(keys))
+	if len(keys) == 0 {
+		return results
+	}
+
+	getter, ok := g.getter.(BatchGetter)
+	if !ok {
+		for _, key := range keys {
+			view, err := g.getLocally(ctx, key, vers[key])
+			results[key] = singleflight.Result{Val: view, Err: err}
+		}
+		return results
+	}
+
+	values, err := getter.GetMulti(ctx, keys)
+	for _, key := range keys {
+		if err != nil {
+			results[key] = singleflight.Result{Err: err}
+			continue
+		}
+		v, ok := values[key]
+		var view ByteView
+		var keyErr error
+		if ok {
+			view, keyErr = g.populateLocal(key, v, nil, vers[key])
+		} else {
+			view, keyErr = g.populateLocal(key, nil, fmt.Errorf("%w: %s", ErrNotFound, key), vers[key])
+		}
+		results[key] = singleflight.Result{Val: view, Err: keyErr}
+	}
+	return results
+}
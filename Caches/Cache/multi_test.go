@@ -0,0 +1,174 @@
+package cache
+
+import (
+	pb "cache/cachepb"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestGetMulti(t *testing.T) {
+	var batches [][]string
+	g := newTestGroup(t, "multi", 2<<10, BatchGetterFunc(
+		func(_ context.Context, keys []string) (map[string][]byte, error) {
+			batches = append(batches, keys)
+			values := make(map[string][]byte)
+			for _, key := range keys {
+				if v, ok := db[key]; ok {
+					values[key] = []byte(v)
+				}
+			}
+			return values, nil
+		}))
+	g.Get("Sam")
+
+	values, err := g.GetMulti([]string{"jw", "boyue", "unknown", "jw", "Sam"})
+	if err != nil {
+		t.Fatalf("GetMulti failed: %v", err)
+	}
+	if len(values) != 3 || values["jw"].String() != "114" || values["boyue"].String() != "514" || values["Sam"].String() != "567" {
+		t.Fatalf("unexpected values %v", values)
+	}
+	// Sam 已缓存，重复的 jw 只加载一次，未命中的 key 合并为一次回源
+	if len(batches) != 2 || !reflect.DeepEqual(batches[1], []string{"jw", "boyue", "unknown"}) {
+		t.Fatalf("unexpected batches %v", batches)
+	}
+
+	if _, err := g.GetMulti([]string{"jw", "boyue"}); err != nil || len(batches) != 2 {
+		t.Fatalf("second GetMulti should hit cache, batches %v", batches)
+	}
+}
+
+// batchPeers 将 values 中的 key 路由到假节点，其余 key 由本地加载
+type batchPeers struct {
+	fakePeers
+	batches [][]string
+	err     error
+}
+
+func (b *batchPeers) PickPeer(key string) (PeerGetter, bool) {
+	_, ok := b.values[key]
+	return b, ok
+}
+
+func (b *batchPeers) GetMulti(ctx context.Context, in *pb.BatchRequest, out *pb.BatchResponse) error {
+	b.batches = append(b.batches, in.Keys)
+	if b.err != nil {
+		return b.err
+	}
+	out.Responses = make([]pb.Response, len(in.Keys))
+	for i, key := range in.Keys {
+		out.Responses[i] = pb.Response{Value: []byte(b.values[key])}
+	}
+	return nil
+}
+
+func TestGetMultiPeers(t *testing.T) {
+	var local []string
+	g := newTestGroup(t, "multi-peers", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			local = append(local, key)
+			return []byte("local-" + key), nil
+		}))
+	peers := &batchPeers{fakePeers: fakePeers{values: map[string]string{"jw": "114", "boyue": "514"}}}
+	g.RegisterPeers(peers)
+
+	values, err := g.GetMulti([]string{"jw", "Sam", "boyue"})
+	if err != nil || values["jw"].String() != "114" || values["boyue"].String() != "514" || values["Sam"].String() != "local-Sam" {
+		t.Fatalf("unexpected values %v, err %v", values, err)
+	}
+	if len(peers.batches) != 1 || len(peers.batches[0]) != 2 || peers.gets != 0 {
+		t.Fatalf("peer keys should be sent in one batch, got %v, gets %d", peers.batches, peers.gets)
+	}
+	if !reflect.DeepEqual(local, []string{"Sam"}) {
+		t.Fatalf("only local keys should be loaded locally, got %v", local)
+	}
+
+	// 节点请求失败时回源到本地 Getter
+	g.removeLocally("jw")
+	peers.err = errors.New("connection refused")
+	if values, err := g.GetMulti([]string{"jw"}); err != nil || values["jw"].String() != "local-jw" {
+		t.Fatalf("should fall back to local getter, got %v err %v", values, err)
+	}
+}
+
+func TestGetMultiUnbatchedPeer(t *testing.T) {
+	g := newTestGroup(t, "multi-unbatched", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			return nil, errors.New("should not load locally")
+		}))
+	peers := &fakePeers{values: db, negativeTTL: time.Minute}
+	g.RegisterPeers(peers)
+
+	values, err := g.GetMulti([]string{"jw", "unknown", "Sam"})
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	if err != nil || !reflect.DeepEqual(keys, []string{"Sam", "jw"}) || peers.gets != 3 {
+		t.Fatalf("unexpected values %v, err %v, gets %d", values, err, peers.gets)
+	}
+}
+
+// TestGetMultiRing 多个节点时，同一归属节点的 key 只发一次批量请求，
+// 不论 PickPeer 返回的是该节点本身还是带有不同副本的 retryGetter
+func TestGetMultiRing(t *testing.T) {
+	g := newTestGroup(t, "multi-ring", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			return []byte(key), nil
+		}))
+
+	var mu sync.Mutex
+	batches := make(map[string]int)
+	peer := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			req := &pb.BatchRequest{}
+			if r.Method != http.MethodPost || req.Unmarshal(body) != nil {
+				t.Errorf("%s: unexpected request %s %s", name, r.Method, r.URL)
+			}
+			mu.Lock()
+			batches[name]++
+			mu.Unlock()
+			res := &pb.BatchResponse{Responses: make([]pb.Response, len(req.Keys))}
+			for i, key := range req.Keys {
+				res.Responses[i] = pb.Response{Value: []byte(name + "-" + key)}
+			}
+			w.Header().Set("Content-Type", pb.ContentType)
+			w.Write(res.Marshal())
+		}))
+	}
+	a, b := peer("a"), peer("b")
+	defer a.Close()
+	defer b.Close()
+
+	self := "http://self"
+	pool := NewHTTPPool(self)
+	pool.Set(self, a.URL, b.URL)
+	g.RegisterPeers(pool)
+
+	keys := make([]string, 100)
+	owners := make(map[string]int)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+		owners[pool.peers.GetN(keys[i], 1)[0]]++
+	}
+	values, err := g.GetMulti(keys)
+	if err != nil || len(values) != len(keys) {
+		t.Fatalf("GetMulti got %d values, err %v", len(values), err)
+	}
+	for name, url := range map[string]string{"a": a.URL, "b": b.URL} {
+		if owners[url] > 0 && batches[name] != 1 {
+			t.Fatalf("peer %s owns %d keys but got %d batches", name, owners[url], batches[name])
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package cache
+
+import "time"
+
+const (
+	// defaultCacheBytes 未设置 WithCacheBytes 时 mainCache 的容量
+	defaultCacheBytes = 64 << 20
+	// defaultLoadTimeout 未设置 WithLoadTimeout 时单次加载的最长时间
+	defaultLoadTimeout = time.Minute
+)
+
+// groupOptions NewGroup 的可选配置
+type groupOptions struct {
+	cacheBytes        int64
+	ttl               time.Duration
+	policy            Policy
+	peers             PeerPicker
+	loadTimeout       time.Duration
+	negativeTTL       time.Duration
+	staleTTL          time.Duration
+	refreshAhead      time.Duration
+	compressThreshold int
+}
+
+// GroupOption 设置 NewGroup 创建的 Group
+type GroupOption func(*groupOptions)
+
+// WithCacheBytes 设置 mainCache 的容量（字节），hotCache 的容量为其 1/hotCacheDivisor，0 表示不限容量
+// 默认为 defaultCacheBytes。缓存按 key 分片，容量平均分给各分片（最多 defaultShards 个，
+// 每个不少于 minShardBytes），单个缓存项（key 与 value 的长度之和）超过一个分片的容量时不会被缓存，
+// 每次 Get 都会回源加载
+func WithCacheBytes(cacheBytes int64) GroupOption {
+	return func(o *groupOptions) {
+		o.cacheBytes = max(cacheBytes, 0)
+	}
+}
+
+// WithTTL 设置缓存项的默认存活时间，超过 ttl 的缓存项在 Get 时视为未命中，会重新回源加载
+// 默认永不过期
+func WithTTL(ttl time.Duration) GroupOption {
+	return func(o *groupOptions) {
+		o.ttl = max(ttl, 0)
+	}
+}
+
+// WithPolicy 设置淘汰策略，默认为 LRU
+func WithPolicy(policy Policy) GroupOption {
+	return func(o *groupOptions) {
+		o.policy = policy
+	}
+}
+
+// WithPeers 设置选择远程节点的 PeerPicker，与创建后调用 RegisterPeers 相同
+func WithPeers(peers PeerPicker) GroupOption {
+	return func(o *groupOptions) {
+		o.peers = peers
+	}
+}
+
+// WithLoadTimeout 设置单次加载（远程节点请求与回源）的最长时间，默认为 defaultLoadTimeout
+// 合并后的加载不随任何一个调用方的 ctx 取消，只在超过 timeout 后取消
+func WithLoadTimeout(timeout time.Duration) GroupOption {
+	return func(o *groupOptions) {
+		if timeout > 0 {
+			o.loadTimeout = timeout
+		}
+	}
+}
+
+// WithNegativeTTL 设置 key 不存在的结果的缓存时间，默认不缓存
+// ttl 应远小于正常缓存项的存活时间，以免数据源新增的 key 长时间不可见
+func WithNegativeTTL(ttl time.Duration) GroupOption {
+	return func(o *groupOptions) {
+		o.negativeTTL = max(ttl, 0)
+	}
+}
+
+// WithStaleWhileRevalidate 设置缓存项过期后仍可返回旧值的时间，默认过期后必须同步重新加载
+// 在此期间读取过期的 key 会立即得到旧值，同时在后台重新加载
+func WithStaleWhileRevalidate(window time.Duration) GroupOption {
+	return func(o *groupOptions) {
+		o.staleTTL = max(window, 0)
+	}
+}
+
+// WithRefreshAhead 设置提前刷新的时间窗口，默认不提前刷新
+// 缓存项剩余存活时间不足 window 时，命中会在后台重新加载，未被访问的 key 则照常过期
+func WithRefreshAhead(window time.Duration) GroupOption {
+	return func(o *groupOptions) {
+		o.refreshAhead = max(window, 0)
+	}
+}
+
+// WithCompression 开启缓存项压缩：长度不小于 threshold 的值以 gzip 压缩后存储，
+// 缓存容量按压缩后的大小计算，读取时 ByteView 自动解压。压缩后没有变小的值仍以原始数据存储。
+// 默认不压缩
+func WithCompression(threshold int) GroupOption {
+	return func(o *groupOptions) {
+		o.compressThreshold = max(threshold, 0)
+	}
+}
@@ -1,11 +1,70 @@
 package cache
 
+import (
+	pb "cache/cachepb"
+	"context"
+	"errors"
+)
+
 // PeerPicker 定义根据 key 选择对应节点的能力
+// GetMulti 会以返回的 PeerGetter 为 map 的键，把归属同一节点的 key 合并为一次请求，
+// 因此 PeerGetter 需要是可比较的，同一节点应返回相等的值
 type PeerPicker interface {
 	PickPeer(key string) (peer PeerGetter, ok bool)
 }
 
+// OwnerPicker 可选接口，返回 key 在哈希环上的归属节点，不考虑节点的健康状况
+// 写请求（Set/Remove）必须发往归属节点：归属节点熔断时，PickPeer 会跳过它，
+// 写请求转而落到其他节点或本地，之后的读请求读不到这次写入
+type OwnerPicker interface {
+	PickOwner(key string) (peer PeerGetter, ok bool)
+}
+
+// pickOwner 返回 key 的归属节点，picker 未实现 OwnerPicker 时退回 PickPeer
+func pickOwner(picker PeerPicker, key string) (PeerGetter, bool) {
+	if op, ok := picker.(OwnerPicker); ok {
+		return op.PickOwner(key)
+	}
+	return picker.PickPeer(key)
+}
+
 // PeerGetter 表示具体节点的客户端能力，可通过 HTTP 等方式拉取远程数据
+// 请求与响应均使用 cachepb 中定义的消息，远程节点返回的错误为 *pb.Error
+// ctx 取消或超时后应尽快返回 ctx.Err()
 type PeerGetter interface {
-	Get(group string, key string) ([]byte, error)
+	Get(ctx context.Context, in *pb.Request, out *pb.Response) error
+	// Remove 通知远程节点删除 key 对应的缓存
+	Remove(ctx context.Context, in *pb.Request) error
+	// Set 将 in.Value 写入远程节点的缓存
+	Set(ctx context.Context, in *pb.Request) error
+}
+
+// BatchPeerGetter 支持批量读取的 PeerGetter，一次请求获取多个 key
+type BatchPeerGetter interface {
+	PeerGetter
+	// GetMulti 读取 in.Keys，out.Responses 与 in.Keys 一一对应
+	GetMulti(ctx context.Context, in *pb.BatchRequest, out *pb.BatchResponse) error
+}
+
+// getMulti 向 peer 批量读取，peer 不支持批量读取时逐个 key 读取
+// 返回 error 表示整个请求失败，单个 key 的错误记录在对应的 Response 中
+func getMulti(ctx context.Context, peer PeerGetter, in *pb.BatchRequest, out *pb.BatchResponse) error {
+	if bp, ok := peer.(BatchPeerGetter); ok {
+		return bp.GetMulti(ctx, in, out)
+	}
+
+	out.Responses = make([]pb.Response, len(in.Keys))
+	for i, key := range in.Keys {
+		res := &out.Responses[i]
+		err := peer.Get(ctx, &pb.Request{Group: in.Group, Key: key, Gen: in.Gen}, res)
+		out.Gen = max(out.Gen, res.Gen)
+		var pe *pb.Error
+		if err != nil && !errors.As(err, &pe) {
+			return err
+		}
+		if err != nil && res.Code == pb.CodeOK {
+			*res = pb.Response{Code: pe.Code, Error: pe.Message}
+		}
+	}
+	return nil
 }
@@ -0,0 +1,51 @@
+package cache
+
+import (
+	"cache/lfu"
+	"cache/lru"
+	"cache/tinylfu"
+	"time"
+)
+
+// Policy 表示 mainCache / hotCache 使用的淘汰策略
+type Policy int
+
+const (
+	// LRU 淘汰最久未访问的数据，默认策略
+	LRU Policy = iota
+	// LFU 淘汰访问次数最少的数据
+	LFU
+	// TinyLFU W-TinyLFU，新数据需要比将被淘汰的数据更频繁才能进入缓存，适合有大量扫描流量的场景
+	TinyLFU
+)
+
+// store 是 cache 底层存储需要满足的接口，每种淘汰策略对应一个实现
+// 实现无需并发安全，由 cache 加锁保护
+type store interface {
+	Get(key string) (value lru.Value, ok bool)
+	AddWithExpire(key string, value lru.Value, expire time.Time)
+	Peek(key string) (lru.Value, bool)
+	Remove(key string) bool
+	RemoveExpired() int
+	Range(fn func(key string, value lru.Value, expire time.Time) bool)
+	Len() int
+	Bytes() int64
+}
+
+var (
+	_ store = (*lru.Cache)(nil)
+	_ store = (*lfu.Cache)(nil)
+	_ store = (*tinylfu.Cache)(nil)
+)
+
+// newStore 根据淘汰策略创建底层存储
+func newStore(policy Policy, maxBytes int64, onEvicted func(string, lru.Value)) store {
+	switch policy {
+	case LFU:
+		return lfu.New(maxBytes, onEvicted)
+	case TinyLFU:
+		return tinylfu.New(maxBytes, onEvicted)
+	default:
+		return lru.New(maxBytes, onEvicted)
+	}
+}
@@ -0,0 +1,94 @@
+package cache
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"testing"
+	"time"
+)
+
+var policies = map[string]Policy{"LRU": LRU, "LFU": LFU, "TinyLFU": TinyLFU}
+
+// zipfTrace 生成服从 Zipf 分布的访问序列
+func zipfTrace(n int, seed int64) []string {
+	r := rand.New(rand.NewSource(seed))
+	z := rand.NewZipf(r, 1.1, 1, 100000)
+	trace := make([]string, n)
+	for i := range trace {
+		trace[i] = "z" + strconv.FormatUint(z.Uint64(), 10)
+	}
+	return trace
+}
+
+// scanTrace 在 Zipf 访问之间穿插大量一次性扫描
+func scanTrace(n int, seed int64) []string {
+	trace := zipfTrace(n, seed)
+	scan := 0
+	for i := range trace {
+		if i%3 == 0 {
+			trace[i] = "s" + strconv.Itoa(scan)
+			scan++
+		}
+	}
+	return trace
+}
+
+// hitRatio 模拟 Group 的读穿透流程，返回命中率
+func hitRatio(policy Policy, cacheBytes int64, trace []string) float64 {
+	s := newStore(policy, cacheBytes, nil)
+	value := ByteView{b: make([]byte, 32)}
+	hits := 0
+	for _, key := range trace {
+		if _, ok := s.Get(key); ok {
+			hits++
+			continue
+		}
+		s.AddWithExpire(key, value, time.Time{})
+	}
+	return float64(hits) / float64(len(trace))
+}
+
+func TestPolicyScanResistance(t *testing.T) {
+	trace := scanTrace(200000, 1)
+	lru := hitRatio(LRU, 64<<10, trace)
+	tiny := hitRatio(TinyLFU, 64<<10, trace)
+	t.Logf("hit ratio on scan trace: LRU %.3f, TinyLFU %.3f", lru, tiny)
+	if tiny <= lru {
+		t.Fatalf("TinyLFU should beat LRU on scan-heavy traffic: %.3f <= %.3f", tiny, lru)
+	}
+}
+
+func TestGroupPolicy(t *testing.T) {
+	for name, policy := range policies {
+		g := newTestGroup(t, "policy"+name, 2<<10, GetterFunc(
+			func(key string) ([]byte, error) {
+				return []byte(key), nil
+			}), WithPolicy(policy))
+		for i := 0; i < 500; i++ {
+			g.Get(strconv.Itoa(i % 100))
+		}
+		if s := g.CacheStats(MainCache); s.Bytes > 2<<10 || s.Hits == 0 {
+			t.Fatalf("%s: unexpected stats %+v", name, s)
+		}
+	}
+}
+
+// BenchmarkHitRatio 比较各淘汰策略在合成访问序列上的命中率，结果以 hit-ratio 指标输出
+func BenchmarkHitRatio(b *testing.B) {
+	traces := map[string][]string{
+		"zipf": zipfTrace(200000, 1),
+		"scan": scanTrace(200000, 1),
+	}
+	for traceName, trace := range traces {
+		for name, policy := range policies {
+			b.Run(fmt.Sprintf("%s/%s", traceName, name), func(b *testing.B) {
+				var ratio float64
+				for i := 0; i < b.N; i++ {
+					ratio = hitRatio(policy, 64<<10, trace)
+				}
+				b.ReportMetric(ratio*100, "hit%")
+			})
+		}
+	}
+}
@@ -0,0 +1,294 @@
+package cache
+
+import (
+	pb "cache/cachepb"
+	"cache/consistenthash"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/rpc"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	// rpcServiceName 注册到 net/rpc 的服务名
+	rpcServiceName = "Cache"
+	// defaultRPCConns 每个远程节点保持的 TCP 连接数
+	defaultRPCConns = 4
+)
+
+// RPCPool 基于 net/rpc 的节点通信实现，作为 HTTPPool 之外的另一种 PeerPicker
+// 节点之间保持持久 TCP 连接，同一连接上的多个请求可以并发进行，
+// 避免每次请求都重新建立 HTTP 连接的开销
+type RPCPool struct {
+	// self 本节点地址，格式为 host:port
+	self string
+
+	//保护peers and rpcGetters
+	mu         sync.Mutex
+	peers      *consistenthash.Map
+	rpcGetters map[string]*rpcGetter
+}
+
+// NewRPCPool 创建 RPCPool，self 为本节点监听的 host:port
+func NewRPCPool(self string) *RPCPool {
+	return &RPCPool{self: self}
+}
+
+// Log 打印服务器日志信息
+func (p *RPCPool) Log(format string, v ...interface{}) {
+	log.Printf("[RPC Server %s] %s", p.self, fmt.Sprintf(format, v...))
+}
+
+// Set 根据给定地址列表初始化一致性哈希环，并为每个地址创建 rpcGetter 客户端
+// 旧的客户端连接会被关闭
+func (p *RPCPool) Set(peers ...string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, getter := range p.rpcGetters {
+		getter.close()
+	}
+	p.peers = consistenthash.New(defaultReplicas, nil)
+	p.peers.Add(peers...)
+	p.rpcGetters = make(map[string]*rpcGetter, len(peers))
+	for _, peer := range peers {
+		p.rpcGetters[peer] = newRPCGetter(peer, defaultRPCConns)
+	}
+}
+
+// PickPeer 根据 key 选择节点，返回节点对应的 RPC 客户端
+func (p *RPCPool) PickPeer(key string) (PeerGetter, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.peers == nil {
+		return nil, false
+	}
+	if peer := p.peers.Get(key); peer != "" && peer != p.self {
+		return p.rpcGetters[peer], true
+	}
+	return nil, false
+}
+
+// PickOwner 返回 key 的归属节点，RPCPool 不做熔断，与 PickPeer 相同
+func (p *RPCPool) PickOwner(key string) (PeerGetter, bool) {
+	return p.PickPeer(key)
+}
+
+var _ PeerPicker = (*RPCPool)(nil)
+
+var _ OwnerPicker = (*RPCPool)(nil)
+
+// Serve 在 lis 上接受其他节点的连接并处理请求，直到 lis 关闭
+func (p *RPCPool) Serve(lis net.Listener) error {
+	server := rpc.NewServer()
+	if err := server.RegisterName(rpcServiceName, &rpcServer{pool: p}); err != nil {
+		return err
+	}
+	p.Log("serving on %s", lis.Addr())
+	server.Accept(lis)
+	return nil
+}
+
+// ListenAndServe 监听 self 地址并处理请求
+func (p *RPCPool) ListenAndServe() error {
+	lis, err := net.Listen("tcp", p.self)
+	if err != nil {
+		return err
+	}
+	return p.Serve(lis)
+}
+
+// rpcServer 处理其他节点的 RPC 请求，方法签名满足 net/rpc 的要求
+// 业务错误放在 pb.Response 中返回，方法本身的 error 只用于表示 RPC 失败
+type rpcServer struct {
+	pool *RPCPool
+}
+
+// group 查找请求对应的 Group，不存在时填充错误响应并返回 nil
+func (s *rpcServer) group(in *pb.Request, out *pb.Response) *Group {
+	s.pool.Log("RPC %s/%s", in.Group, in.Key)
+	group := s.lookup(in.Group)
+	if group == nil {
+		*out = pb.Response{Code: pb.CodeNotFound, Error: "no such group: " + in.Group}
+	}
+	return group
+}
+
+// lookup 查找 Group 并计数，不存在时返回 nil
+func (s *rpcServer) lookup(name string) *Group {
+	group := GetGroup(name)
+	if group != nil {
+		group.stats.serverRequests.Add(1)
+	}
+	return group
+}
+
+func (s *rpcServer) Get(in *pb.Request, out *pb.Response) error {
+	group := s.group(in, out)
+	if group == nil {
+		return nil
+	}
+	*out = *group.getResponse(context.Background(), in.Key, in.Gen)
+	return nil
+}
+
+func (s *rpcServer) GetMulti(in *pb.BatchRequest, out *pb.BatchResponse) error {
+	s.pool.Log("RPC %s/%d keys", in.Group, len(in.Keys))
+	group := s.lookup(in.Group)
+	if group == nil {
+		*out = pb.BatchResponse{Code: pb.CodeNotFound, Error: "no such group: " + in.Group}
+		return nil
+	}
+	*out = *group.getMultiResponse(context.Background(), in.Keys, in.Gen)
+	return nil
+}
+
+func (s *rpcServer) Set(in *pb.Request, out *pb.Response) error {
+	if group := s.group(in, out); group != nil {
+		group.advanceGeneration(in.Gen)
+		group.setLocally(in.Key, in.Value, in.TTL)
+		out.Gen = group.Generation()
+	}
+	return nil
+}
+
+func (s *rpcServer) Remove(in *pb.Request, out *pb.Response) error {
+	if group := s.group(in, out); group != nil {
+		group.advanceGeneration(in.Gen)
+		group.removeLocally(in.Key)
+		out.Gen = group.Generation()
+	}
+	return nil
+}
+
+// rpcGetter 远程节点的 RPC 客户端，维护一个固定大小的连接池
+// 每个 rpc.Client 本身支持在一条连接上并发多路请求，
+// 连接池只是为了分散单条 TCP 连接的队头阻塞
+type rpcGetter struct {
+	addr  string
+	next  atomic.Uint32
+	mu    sync.Mutex // 保护 conns
+	conns []*rpc.Client
+}
+
+func newRPCGetter(addr string, size int) *rpcGetter {
+	return &rpcGetter{
+		addr:  addr,
+		conns: make([]*rpc.Client, size),
+	}
+}
+
+// client 以轮询方式取出一个连接，连接不存在时建立新连接
+// 拨号在锁外进行并受 ctx 约束，不可达的节点不会阻塞其他连接的使用
+func (h *rpcGetter) client(ctx context.Context) (int, *rpc.Client, error) {
+	i := int(h.next.Add(1)) % len(h.conns)
+
+	h.mu.Lock()
+	c := h.conns[i]
+	h.mu.Unlock()
+	if c != nil {
+		return i, c, nil
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", h.addr)
+	if err != nil {
+		return i, nil, err
+	}
+	c = rpc.NewClient(conn)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.conns[i] != nil {
+		// 其他调用方已先建立了连接，使用已有的
+		c.Close()
+		return i, h.conns[i], nil
+	}
+	h.conns[i] = c
+	return i, c, nil
+}
+
+// discard 连接失效后将其移出连接池，下次使用时重新建立
+func (h *rpcGetter) discard(i int, c *rpc.Client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.conns[i] == c {
+		h.conns[i] = nil
+		c.Close()
+	}
+}
+
+// close 关闭所有连接
+func (h *rpcGetter) close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, c := range h.conns {
+		if c != nil {
+			c.Close()
+			h.conns[i] = nil
+		}
+	}
+}
+
+// call 发起一次 RPC 调用，远程节点返回的错误为 *pb.Error
+func (h *rpcGetter) call(ctx context.Context, method string, in *pb.Request, out *pb.Response) error {
+	// 使用独立的 reply，避免 ctx 结束后迟到的响应写入调用方的 out
+	reply := &pb.Response{}
+	if err := h.invoke(ctx, method, in, reply); err != nil {
+		return err
+	}
+	*out = *reply
+	return out.Err()
+}
+
+// invoke 发起一次 RPC 调用，只返回传输错误
+// net/rpc 不支持取消，ctx 结束后直接返回，迟到的响应写入 reply 后被丢弃
+func (h *rpcGetter) invoke(ctx context.Context, method string, args, reply interface{}) error {
+	i, c, err := h.client(ctx)
+	if err != nil {
+		return err
+	}
+	call := c.Go(rpcServiceName+"."+method, args, reply, make(chan *rpc.Call, 1))
+	select {
+	case <-call.Done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if err := call.Error; err != nil {
+		var serverErr rpc.ServerError
+		if !errors.As(err, &serverErr) {
+			// 非服务端错误说明连接已不可用
+			h.discard(i, c)
+		}
+		return err
+	}
+	return nil
+}
+
+func (h *rpcGetter) Get(ctx context.Context, in *pb.Request, out *pb.Response) error {
+	return h.call(ctx, "Get", in, out)
+}
+
+// GetMulti 一次 RPC 调用批量读取
+func (h *rpcGetter) GetMulti(ctx context.Context, in *pb.BatchRequest, out *pb.BatchResponse) error {
+	reply := &pb.BatchResponse{}
+	if err := h.invoke(ctx, "GetMulti", in, reply); err != nil {
+		return err
+	}
+	*out = *reply
+	return out.Err()
+}
+
+func (h *rpcGetter) Remove(ctx context.Context, in *pb.Request) error {
+	return h.call(ctx, "Remove", in, &pb.Response{})
+}
+
+func (h *rpcGetter) Set(ctx context.Context, in *pb.Request) error {
+	return h.call(ctx, "Set", in, &pb.Response{})
+}
+
+var _ BatchPeerGetter = (*rpcGetter)(nil)
@@ -0,0 +1,59 @@
+package cache
+
+import (
+	pb "cache/cachepb"
+	"context"
+	"errors"
+	"net"
+	"testing"
+)
+
+func TestRPCGetter(t *testing.T) {
+	ctx := context.Background()
+	g := newTestGroup(t, "rpc", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			return []byte(key), nil
+		}))
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lis.Close()
+	go NewRPCPool(lis.Addr().String()).Serve(lis)
+
+	h := newRPCGetter(lis.Addr().String(), 2)
+	defer h.close()
+
+	for i := 0; i < 4; i++ {
+		res := &pb.Response{}
+		if err := h.Get(ctx, &pb.Request{Group: "rpc", Key: "jw"}, res); err != nil || string(res.Value) != "jw" {
+			t.Fatalf("Get got %q, err %v", res.Value, err)
+		}
+	}
+
+	batch := &pb.BatchResponse{}
+	if err := h.GetMulti(ctx, &pb.BatchRequest{Group: "rpc", Keys: []string{"jw", "boyue"}}, batch); err != nil ||
+		len(batch.Responses) != 2 || string(batch.Responses[1].Value) != "boyue" {
+		t.Fatalf("GetMulti got %+v, err %v", batch, err)
+	}
+
+	if err := h.Set(ctx, &pb.Request{Group: "rpc", Key: "Sam", Value: []byte("567")}); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if v, ok := g.mainCache.get("Sam"); !ok || v.String() != "567" {
+		t.Fatalf("Set should write into main cache")
+	}
+	if err := h.Remove(ctx, &pb.Request{Group: "rpc", Key: "Sam"}); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	if _, ok := g.mainCache.get("Sam"); ok {
+		t.Fatalf("Remove should delete from main cache")
+	}
+
+	var pe *pb.Error
+	err = h.Get(ctx, &pb.Request{Group: "unknown", Key: "jw"}, &pb.Response{})
+	if !errors.As(err, &pe) || pe.Code != pb.CodeNotFound {
+		t.Fatalf("unknown group should return CodeNotFound, got %v", err)
+	}
+}
@@ -1,12 +1,53 @@
 package singleflight
 
-import "sync"
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"sync"
+)
+
+// errGoexit 表示 fn 调用了 runtime.Goexit
+var errGoexit = errors.New("runtime.Goexit was called")
+
+// panicError 保存 fn panic 时的值及堆栈，等待者收到后会重新 panic
+type panicError struct {
+	value interface{}
+	stack []byte
+}
+
+func (p *panicError) Error() string {
+	return fmt.Sprintf("%v\n\n%s", p.value, p.stack)
+}
+
+func (p *panicError) Unwrap() error {
+	err, ok := p.value.(error)
+	if !ok {
+		return nil
+	}
+	return err
+}
+
+func newPanicError(v interface{}) error {
+	stack := debug.Stack()
+	// 第一行是 "goroutine N [status]:"，协程已经不在该状态，去掉以免误导
+	if line := bytes.IndexByte(stack, '\n'); line >= 0 {
+		stack = stack[line+1:]
+	}
+	return &panicError{value: v, stack: stack}
+}
 
 // call 代表正在进行中，或已经结束的请求
 type call struct {
 	wg  sync.WaitGroup
 	val interface{}
 	err error
+
+	// dups 等待该调用结果的其他调用者数量，chans 为 DoChan 调用者的结果通道
+	dups  int
+	chans []chan<- Result
 }
 
 // Group 管理不同 key 的请求(call)
@@ -15,8 +56,17 @@ type Group struct {
 	m  map[string]*call
 }
 
+// Result 保存一次调用的结果，Shared 表示结果是否同时返回给了多个调用者
+type Result struct {
+	Val    interface{}
+	Err    error
+	Shared bool
+}
+
 // Do 执行给定的函数fn 并确保同一时刻相同的key只会执行一次
-func (g *Group) Do(key string, fn func() (interface{}, error)) (interface{}, error) {
+// 重复的调用等待第一个调用完成并得到相同的结果，shared 表示结果是否被多个调用者共享。
+// fn panic 或调用 runtime.Goexit 时，所有等待者也会 panic 或退出，而不是永远阻塞
+func (g *Group) Do(key string, fn func() (interface{}, error)) (v interface{}, err error, shared bool) {
 	g.mu.Lock()
 	if g.m == nil {
 		g.m = make(map[string]*call)
@@ -24,14 +74,17 @@ func (g *Group) Do(key string, fn func() (interface{}, error)) (interface{}, err
 
 	// 已有相同 key 的调用在执行：释放锁后等待完成，直接复用结果
 	if c, ok := g.m[key]; ok {
+		c.dups++
 		g.mu.Unlock()
 		c.wg.Wait()
-		return c.val, c.err
-	}
 
-	// wg.Add(1) 锁加1。
-	// wg.Wait() 阻塞，直到锁被释放。
-	// wg.Done() 锁减1。
+		if e, ok := c.err.(*panicError); ok {
+			panic(e)
+		} else if c.err == errGoexit {
+			runtime.Goexit()
+		}
+		return c.val, c.err, true
+	}
 
 	// 无调用,注册新的 call，当前协程成为首个调用者
 	c := new(call)
@@ -39,14 +92,193 @@ func (g *Group) Do(key string, fn func() (interface{}, error)) (interface{}, err
 	g.m[key] = c
 	g.mu.Unlock()
 
-	// 调用 fn，发起请求
-	c.val, c.err = fn()
-	c.wg.Done() // 请求结束
+	g.doCall(c, key, fn)
+	return c.val, c.err, c.dups > 0
+}
 
-	// 更新 g.m
+// DoChan 与 Do 相同，但立即返回一个通道，结果就绪后从通道中送出
+// 调用者可以配合 select 在等待时放弃，不影响其他等待者；通道不会被关闭
+func (g *Group) DoChan(key string, fn func() (interface{}, error)) <-chan Result {
+	ch := make(chan Result, 1)
+	g.mu.Lock()
+	if g.m == nil {
+		g.m = make(map[string]*call)
+	}
+	if c, ok := g.m[key]; ok {
+		c.dups++
+		c.chans = append(c.chans, ch)
+		g.mu.Unlock()
+		return ch
+	}
+	c := &call{chans: []chan<- Result{ch}}
+	c.wg.Add(1)
+	g.m[key] = c
+	g.mu.Unlock()
+
+	go g.doCall(c, key, fn)
+
+	return ch
+}
+
+// doCall 执行 fn 并唤醒所有等待者
+func (g *Group) doCall(c *call, key string, fn func() (interface{}, error)) {
+	normalReturn := false
+	recovered := false
+
+	// 使用两层 defer 区分 panic 与 runtime.Goexit：
+	// Goexit 不能被 recover，只会执行 defer，此时 normalReturn 和 recovered 都为 false
+	defer func() {
+		if !normalReturn && !recovered {
+			c.err = errGoexit
+		}
+
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		c.wg.Done()
+		// 调用过 Forget 时 key 可能已经对应新的 call
+		if g.m[key] == c {
+			delete(g.m, key)
+		}
+
+		if e, ok := c.err.(*panicError); ok {
+			// DoChan 的等待者无法在自己的协程里收到 panic，
+			// 只能在新协程中重新 panic 使进程崩溃，避免永远阻塞
+			if len(c.chans) > 0 {
+				go panic(e)
+				select {} // 保留当前协程，使其出现在崩溃时的堆栈中
+			} else {
+				panic(e)
+			}
+		} else if c.err == errGoexit {
+			// 当前协程已经在 Goexit 流程中，无需再做处理
+		} else {
+			for _, ch := range c.chans {
+				ch <- Result{c.val, c.err, c.dups > 0}
+			}
+		}
+	}()
+
+	func() {
+		defer func() {
+			if !normalReturn {
+				// 此处的 recover 只捕获 panic，Goexit 时 recover 返回 nil
+				if r := recover(); r != nil {
+					c.err = newPanicError(r)
+				}
+			}
+		}()
+
+		c.val, c.err = fn()
+		normalReturn = true
+	}()
+
+	if !normalReturn {
+		recovered = true
+	}
+}
+
+// Forget 使 key 对应的进行中调用被遗忘，之后相同 key 的调用会重新执行 fn，
+// 而不是等待之前的调用。已经在等待的调用者仍然得到之前调用的结果
+func (g *Group) Forget(key string) {
 	g.mu.Lock()
 	delete(g.m, key)
 	g.mu.Unlock()
+}
+
+// DoMulti 对一批 key 执行 fn，与 Do 共享进行中的调用：
+// 已有调用在执行的 key 直接等待其结果，其余 key 合并为一次 fn 调用。
+// fn 只会收到尚未在执行的 key，返回结果中缺少的 key 视为 nil, nil。
+// fn panic 时，等待这些 key 的调用者同样会 panic
+func (g *Group) DoMulti(keys []string, fn func(keys []string) map[string]Result) map[string]Result {
+	g.mu.Lock()
+	if g.m == nil {
+		g.m = make(map[string]*call)
+	}
+
+	calls := make(map[string]*call, len(keys))
+	var owned []string
+	for _, key := range keys {
+		if _, ok := calls[key]; ok {
+			continue
+		}
+		if c, ok := g.m[key]; ok {
+			c.dups++
+			calls[key] = c
+			continue
+		}
+		c := new(call)
+		c.wg.Add(1)
+		g.m[key] = c
+		calls[key] = c
+		owned = append(owned, key)
+	}
+	g.mu.Unlock()
+
+	// 先完成自己负责的 key 再等待其他调用，多个 DoMulti 互相等待也不会死锁
+	if len(owned) > 0 {
+		g.doMultiCall(calls, owned, fn)
+	}
+
+	results := make(map[string]Result, len(calls))
+	for key, c := range calls {
+		c.wg.Wait()
+		if e, ok := c.err.(*panicError); ok {
+			panic(e)
+		} else if c.err == errGoexit {
+			runtime.Goexit()
+		}
+		results[key] = Result{c.val, c.err, c.dups > 0}
+	}
+	return results
+}
+
+// doMultiCall 执行 fn 并唤醒 owned 中各 key 的等待者，fn 异常退出时同样唤醒
+func (g *Group) doMultiCall(calls map[string]*call, owned []string, fn func(keys []string) map[string]Result) {
+	var results map[string]Result
+	var err error
+	normalReturn := false
+	defer func() {
+		if !normalReturn {
+			if r := recover(); r != nil {
+				err = newPanicError(r)
+			} else {
+				err = errGoexit
+			}
+		}
+
+		g.mu.Lock()
+		for _, key := range owned {
+			c := calls[key]
+			if err != nil {
+				c.err = err
+			} else {
+				c.val, c.err = results[key].Val, results[key].Err
+			}
+			c.wg.Done()
+			if g.m[key] == c {
+				delete(g.m, key)
+			}
+			if err != nil {
+				continue
+			}
+			for _, ch := range c.chans {
+				ch <- Result{c.val, c.err, c.dups > 0}
+			}
+		}
+		g.mu.Unlock()
+
+		if e, ok := err.(*panicError); ok {
+			// 与 doCall 相同，DoChan 的等待者只能通过新协程中的 panic 得知
+			for _, key := range owned {
+				if len(calls[key].chans) > 0 {
+					go panic(e)
+					select {}
+				}
+			}
+			panic(e)
+		}
+	}()
 
-	return c.val, c.err
+	results = fn(owned)
+	normalReturn = true
 }
@@ -0,0 +1,289 @@
+package singleflight
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDo(t *testing.T) {
+	var g Group
+	v, err, shared := g.Do("key", func() (interface{}, error) {
+		return "bar", nil
+	})
+	if v != "bar" || err != nil || shared {
+		t.Fatalf("Do = %v, %v, %v", v, err, shared)
+	}
+}
+
+func TestDoErr(t *testing.T) {
+	var g Group
+	someErr := errors.New("some error")
+	v, err, _ := g.Do("key", func() (interface{}, error) {
+		return nil, someErr
+	})
+	if err != someErr || v != nil {
+		t.Fatalf("Do = %v, %v, want nil, %v", v, err, someErr)
+	}
+}
+
+// TestDoDupSuppress 并发的重复调用只执行一次 fn，且都得到共享的结果
+func TestDoDupSuppress(t *testing.T) {
+	var g Group
+	var calls atomic.Int32
+	release := make(chan struct{})
+	started := make(chan struct{})
+	fn := func() (interface{}, error) {
+		if calls.Add(1) == 1 {
+			close(started)
+		}
+		<-release
+		return "bar", nil
+	}
+
+	const n = 10
+	var wg sync.WaitGroup
+	var sharedCount atomic.Int32
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if _, _, shared := g.Do("key", fn); shared {
+			sharedCount.Add(1)
+		}
+	}()
+	<-started
+	for i := 1; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err, shared := g.Do("key", fn)
+			if v != "bar" || err != nil {
+				t.Errorf("Do = %v, %v", v, err)
+			}
+			if shared {
+				sharedCount.Add(1)
+			}
+		}()
+	}
+	// 等待重复调用都进入等待状态
+	for {
+		g.mu.Lock()
+		dups := g.m["key"].dups
+		g.mu.Unlock()
+		if dups == n-1 {
+			break
+		}
+		runtime.Gosched()
+	}
+	close(release)
+	wg.Wait()
+
+	if got := calls.Load(); got != 1 {
+		t.Fatalf("fn called %d times, want 1", got)
+	}
+	if got := sharedCount.Load(); got != n {
+		t.Fatalf("%d callers saw shared result, want %d", got, n)
+	}
+}
+
+func TestDoChan(t *testing.T) {
+	var g Group
+	release := make(chan struct{})
+	ch1 := g.DoChan("key", func() (interface{}, error) {
+		<-release
+		return "bar", nil
+	})
+	ch2 := g.DoChan("key", func() (interface{}, error) {
+		t.Error("duplicate DoChan should not call fn")
+		return nil, nil
+	})
+
+	select {
+	case <-ch1:
+		t.Fatal("DoChan should not block the caller until fn returns")
+	default:
+	}
+	close(release)
+	for _, ch := range []<-chan Result{ch1, ch2} {
+		if r := <-ch; r.Val != "bar" || r.Err != nil || !r.Shared {
+			t.Fatalf("DoChan = %+v", r)
+		}
+	}
+}
+
+func TestForget(t *testing.T) {
+	var g Group
+	release := make(chan struct{})
+	ch1 := g.DoChan("key", func() (interface{}, error) {
+		<-release
+		return 1, nil
+	})
+	g.Forget("key")
+
+	// 遗忘之后重新执行 fn，而不是等待之前的调用
+	v, _, shared := g.Do("key", func() (interface{}, error) {
+		return 2, nil
+	})
+	if v != 2 || shared {
+		t.Fatalf("Do after Forget = %v, shared %v, want 2", v, shared)
+	}
+
+	close(release)
+	if r := <-ch1; r.Val != 1 {
+		t.Fatalf("forgotten call = %v, want 1", r.Val)
+	}
+	// 被遗忘的调用结束时不应删除新的调用
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if len(g.m) != 0 {
+		t.Fatalf("calls should be cleaned up, got %v", g.m)
+	}
+}
+
+// TestPanicDo fn panic 时，首个调用者和等待者都会收到 panic，而不是永远阻塞
+func TestPanicDo(t *testing.T) {
+	var g Group
+	started := make(chan struct{})
+	release := make(chan struct{})
+	fn := func() (interface{}, error) {
+		close(started)
+		<-release
+		panic("invalid memory address or nil pointer dereference")
+	}
+
+	const n = 5
+	panics := make(chan interface{}, n)
+	var wg sync.WaitGroup
+	do := func() {
+		defer wg.Done()
+		defer func() {
+			panics <- recover()
+		}()
+		g.Do("key", fn)
+	}
+	wg.Add(1)
+	go do()
+	<-started
+	for i := 1; i < n; i++ {
+		wg.Add(1)
+		go do()
+	}
+	for {
+		g.mu.Lock()
+		dups := g.m["key"].dups
+		g.mu.Unlock()
+		if dups == n-1 {
+			break
+		}
+		runtime.Gosched()
+	}
+	close(release)
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Do hangs after fn panics")
+	}
+	close(panics)
+	count := 0
+	for p := range panics {
+		if _, ok := p.(*panicError); !ok {
+			t.Fatalf("expect *panicError, got %v", p)
+		}
+		count++
+	}
+	if count != n {
+		t.Fatalf("%d callers panicked, want %d", count, n)
+	}
+}
+
+func TestGoexitDo(t *testing.T) {
+	var g Group
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		g.Do("key", func() (interface{}, error) {
+			runtime.Goexit()
+			return nil, nil
+		})
+		t.Error("Do should not return after fn calls Goexit")
+	}()
+	<-done
+
+	// 调用结束后 key 被清理，之后的调用正常执行
+	if v, err, _ := g.Do("key", func() (interface{}, error) { return 1, nil }); v != 1 || err != nil {
+		t.Fatalf("Do after Goexit = %v, %v", v, err)
+	}
+}
+
+func TestDoMulti(t *testing.T) {
+	var g Group
+	release := make(chan struct{})
+	ch := g.DoChan("b", func() (interface{}, error) {
+		<-release
+		return "B", nil
+	})
+
+	var batches [][]string
+	go func() {
+		for {
+			g.mu.Lock()
+			dups := g.m["b"].dups
+			g.mu.Unlock()
+			if dups > 0 {
+				close(release)
+				return
+			}
+			runtime.Gosched()
+		}
+	}()
+	results := g.DoMulti([]string{"a", "b", "c", "a"}, func(keys []string) map[string]Result {
+		batches = append(batches, keys)
+		out := make(map[string]Result, len(keys))
+		for _, key := range keys {
+			out[key] = Result{Val: key + key}
+		}
+		return out
+	})
+
+	// b 已在进行中，只有 a、c 交给 fn，重复的 a 只出现一次
+	if !reflect.DeepEqual(batches, [][]string{{"a", "c"}}) {
+		t.Fatalf("unexpected batches %v", batches)
+	}
+	got := make(map[string]interface{}, len(results))
+	for key, r := range results {
+		got[key] = r.Val
+	}
+	if want := map[string]interface{}{"a": "aa", "b": "B", "c": "cc"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("DoMulti = %v, want %v", got, want)
+	}
+	if r := <-ch; r.Val != "B" || !r.Shared {
+		t.Fatalf("DoChan = %+v", r)
+	}
+}
+
+func TestDoMultiPanic(t *testing.T) {
+	var g Group
+	defer func() {
+		if _, ok := recover().(*panicError); !ok {
+			t.Fatal("DoMulti should propagate the panic")
+		}
+		// panic 后 key 被清理，之后的调用正常执行
+		if v, _, _ := g.Do("a", func() (interface{}, error) { return 1, nil }); v != 1 {
+			t.Fatalf("Do after panic = %v", v)
+		}
+	}()
+	g.DoMulti([]string{"a"}, func(keys []string) map[string]Result {
+		panic(fmt.Sprintf("load %v", keys))
+	})
+}
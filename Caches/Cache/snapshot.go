@@ -0,0 +1,237 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// 快照文件格式：
+//
+//	magic(4) | version(1) | generation(uvarint) | count(uvarint) | entry... | crc32(4)
+//	entry = len(key) key | len(value) value | expire(varint，UnixNano，0 表示永不过期)
+//
+// 长度均为 uvarint，crc32 (IEEE) 覆盖其之前的全部内容，以大端序写在文件末尾。
+// 版本 1 没有 generation，其中的缓存项按代数 0 载入
+const (
+	snapshotMagic   = "GCSN"
+	snapshotVersion = 2
+)
+
+// ErrCorruptSnapshot 快照文件被截断、校验失败或版本不受支持
+var ErrCorruptSnapshot = errors.New("cache: corrupt snapshot")
+
+// SaveSnapshot 将 mainCache 中未过期的缓存项（负缓存项除外）写入 path
+// 先写临时文件再重命名，保存过程中崩溃不会破坏已有的快照
+func (g *Group) SaveSnapshot(path string) error {
+	var body bytes.Buffer
+	count := 0
+	now := time.Now()
+	gen := g.generation.Load()
+	g.mainCache.rangeEntries(func(key string, value ByteView, _ time.Time) bool {
+		// 负缓存项存活时间很短，不值得持久化；已过期或已失效的旧值也不再保存
+		expire := value.e
+		if value.notFound || value.ver.gen < gen || (!expire.IsZero() && !now.Before(expire)) {
+			return true
+		}
+		body.Write(binary.AppendUvarint(nil, uint64(len(key))))
+		body.WriteString(key)
+		// 快照中保存原始数据，加载时再按当前的压缩设置存储
+		b := value.b
+		if value.compressed {
+			b = value.uncompressed()
+		}
+		body.Write(binary.AppendUvarint(nil, uint64(len(b))))
+		body.Write(b)
+		var e int64
+		if !expire.IsZero() {
+			e = expire.UnixNano()
+		}
+		body.Write(binary.AppendVarint(nil, e))
+		count++
+		return true
+	})
+
+	buf := make([]byte, 0, len(snapshotMagic)+1+2*binary.MaxVarintLen64+body.Len()+crc32.Size)
+	buf = append(buf, snapshotMagic...)
+	buf = append(buf, snapshotVersion)
+	buf = binary.AppendUvarint(buf, gen)
+	buf = binary.AppendUvarint(buf, uint64(count))
+	buf = append(buf, body.Bytes()...)
+	buf = binary.BigEndian.AppendUint32(buf, crc32.ChecksumIEEE(buf))
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(buf); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// LoadSnapshot 从 path 读取快照并写入 mainCache，返回载入的缓存项数量
+// 本地代数推进到快照保存时的代数，缓存项仍属于保存时的代数：
+// 重启前后错过的 Invalidate 在与其他节点通信、追上集群的代数后使这些缓存项失效。
+// 已过期或已失效的缓存项会被跳过；文件损坏时返回 ErrCorruptSnapshot，且不会载入任何缓存项
+func (g *Group) LoadSnapshot(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	gen, entries, err := decodeSnapshot(data)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s: %v", ErrCorruptSnapshot, path, err)
+	}
+
+	g.advanceGeneration(gen)
+	if gen < g.Generation() {
+		// 快照保存之后已经 Invalidate 过，其中的缓存项全部失效
+		return 0, nil
+	}
+	now := time.Now()
+	n := 0
+	for _, ent := range entries {
+		if !ent.value.e.IsZero() && !now.Before(ent.value.e) {
+			continue
+		}
+		ent.value.ver = version{gen: gen, seq: g.writeSeq(ent.key).Load()}
+		g.populateCache(ent.key, ent.value, g.mainCache)
+		n++
+	}
+	return n, nil
+}
+
+// snapshotEntry 快照中的一个缓存项
+type snapshotEntry struct {
+	key   string
+	value ByteView
+}
+
+// decodeSnapshot 校验并解析快照，返回保存时的代数与缓存项，全部解析成功后才返回结果
+func decodeSnapshot(data []byte) (uint64, []snapshotEntry, error) {
+	if len(data) < len(snapshotMagic)+1+crc32.Size {
+		return 0, nil, errors.New("truncated")
+	}
+	body, sum := data[:len(data)-crc32.Size], data[len(data)-crc32.Size:]
+	if crc32.ChecksumIEEE(body) != binary.BigEndian.Uint32(sum) {
+		return 0, nil, errors.New("checksum mismatch")
+	}
+	if string(body[:len(snapshotMagic)]) != snapshotMagic {
+		return 0, nil, errors.New("bad magic")
+	}
+	v := body[len(snapshotMagic)]
+	if v < 1 || v > snapshotVersion {
+		return 0, nil, fmt.Errorf("unsupported version %d", v)
+	}
+	body = body[len(snapshotMagic)+1:]
+
+	var gen uint64
+	if v >= 2 {
+		var k int
+		if gen, k = binary.Uvarint(body); k <= 0 {
+			return 0, nil, errors.New("truncated")
+		}
+		body = body[k:]
+	}
+
+	readBytes := func() ([]byte, bool) {
+		n, k := binary.Uvarint(body)
+		if k <= 0 || uint64(len(body)-k) < n {
+			return nil, false
+		}
+		b := body[k : k+int(n)]
+		body = body[k+int(n):]
+		return b, true
+	}
+
+	count, k := binary.Uvarint(body)
+	if k <= 0 {
+		return 0, nil, errors.New("truncated")
+	}
+	body = body[k:]
+
+	entries := make([]snapshotEntry, 0, min(count, uint64(len(body))))
+	for i := uint64(0); i < count; i++ {
+		key, ok := readBytes()
+		if !ok {
+			return 0, nil, errors.New("truncated")
+		}
+		value, ok := readBytes()
+		if !ok {
+			return 0, nil, errors.New("truncated")
+		}
+		e, k := binary.Varint(body)
+		if k <= 0 {
+			return 0, nil, errors.New("truncated")
+		}
+		body = body[k:]
+
+		var expire time.Time
+		if e != 0 {
+			expire = time.Unix(0, e)
+		}
+		entries = append(entries, snapshotEntry{string(key), ByteView{b: cloneBytes(value), e: expire}})
+	}
+	if len(body) != 0 {
+		return 0, nil, errors.New("trailing data")
+	}
+	return gen, entries, nil
+}
+
+// StartSnapshots 每隔 interval 将 mainCache 保存到 path，重复调用会替换之前的定时任务
+// 停止旧任务与登记新任务在同一临界区内完成，并发调用时最终只有一个任务在运行
+func (g *Group) StartSnapshots(path string, interval time.Duration) {
+	stop := make(chan struct{})
+	g.snapshotMu.Lock()
+	g.stopSnapshotsLocked()
+	g.snapshotStop = stop
+	g.snapshotMu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := g.SaveSnapshot(path); err != nil {
+					log.Printf("[Cache] save snapshot of group %s: %v", g.name, err)
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// StopSnapshots 停止定时保存快照，不会再额外保存一次
+func (g *Group) StopSnapshots() {
+	g.snapshotMu.Lock()
+	defer g.snapshotMu.Unlock()
+
+	g.stopSnapshotsLocked()
+}
+
+// stopSnapshotsLocked 停止当前的定时任务，调用方需持有 snapshotMu
+func (g *Group) stopSnapshotsLocked() {
+	if g.snapshotStop != nil {
+		close(g.snapshotStop)
+		g.snapshotStop = nil
+	}
+}
@@ -0,0 +1,143 @@
+package cache
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSnapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot")
+	src := newTestGroup(t, "snapshot-src", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return []byte("v-" + key), nil
+	}))
+	src.setLocally("forever", []byte("1"), 0)
+	src.setLocally("later", []byte("2"), time.Hour)
+	src.setLocally("soon", []byte("3"), 10*time.Millisecond)
+	if err := src.SaveSnapshot(path); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	loads := 0
+	dst := newTestGroup(t, "snapshot-dst", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		loads++
+		return []byte("v-" + key), nil
+	}))
+	n, err := dst.LoadSnapshot(path)
+	if err != nil || n != 2 {
+		t.Fatalf("LoadSnapshot = %d, %v, want 2 entries", n, err)
+	}
+	if v, _ := dst.Get("forever"); v.String() != "1" || !v.Expire().IsZero() {
+		t.Fatalf("forever = %q, expire %v", v.String(), v.Expire())
+	}
+	if v, _ := dst.Get("later"); v.String() != "2" || time.Until(v.Expire()) <= 50*time.Minute {
+		t.Fatalf("later = %q, expire %v", v.String(), v.Expire())
+	}
+	if loads != 0 {
+		t.Fatalf("restored keys should not reload, loads %d", loads)
+	}
+	if v, _ := dst.Get("soon"); v.String() != "v-soon" || loads != 1 {
+		t.Fatalf("expired entry should not be restored, got %q", v.String())
+	}
+}
+
+// TestConcurrentStartSnapshots 并发调用 StartSnapshots 不会留下无法停止的定时任务
+func TestConcurrentStartSnapshots(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot")
+	g := newTestGroup(t, "snapshot-concurrent", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return []byte(key), nil
+	}))
+	g.setLocally("jw", []byte("1"), 0)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			g.StartSnapshots(path, time.Millisecond)
+			g.StopSnapshots()
+			g.StartSnapshots(path, time.Millisecond)
+		}()
+	}
+	wg.Wait()
+	g.StopSnapshots()
+
+	// 等待进行中的保存完成，之后不应再有任务写入快照
+	time.Sleep(20 * time.Millisecond)
+	os.Remove(path)
+	time.Sleep(20 * time.Millisecond)
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("snapshot written after StopSnapshots, stat err %v", err)
+	}
+}
+
+// TestSnapshotGeneration 快照保存代数，载入后不会把旧代数的缓存项当作当前的
+func TestSnapshotGeneration(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot")
+	src := newTestGroup(t, "snapshot-gen-src", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return []byte(key), nil
+	}))
+	src.advanceGeneration(2)
+	src.setLocally("jw", []byte("114"), 0)
+	if err := src.SaveSnapshot(path); err != nil {
+		t.Fatal(err)
+	}
+
+	// 重启的节点恢复保存时的代数
+	dst := newTestGroup(t, "snapshot-gen-dst", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return []byte(key), nil
+	}))
+	if n, err := dst.LoadSnapshot(path); err != nil || n != 1 || dst.Generation() != 2 {
+		t.Fatalf("LoadSnapshot = %d, %v, generation %d", n, err, dst.Generation())
+	}
+	if info, ok := dst.peek("jw"); !ok || string(info.Value) != "114" {
+		t.Fatalf("restored entry should be visible")
+	}
+	// 追上集群中更大的代数后，快照中的缓存项失效
+	dst.advanceGeneration(3)
+	if _, ok := dst.peek("jw"); ok {
+		t.Fatalf("entries from an older generation should be invalid")
+	}
+
+	// 已经 Invalidate 过的节点不再载入旧快照
+	dst.removeLocally("jw")
+	if n, err := dst.LoadSnapshot(path); err != nil || n != 0 || dst.Generation() != 3 {
+		t.Fatalf("LoadSnapshot = %d, %v, generation %d", n, err, dst.Generation())
+	}
+}
+
+func TestCorruptSnapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot")
+	g := newTestGroup(t, "snapshot-corrupt", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return []byte(key), nil
+	}))
+	g.setLocally("jw", []byte("114"), 0)
+	if err := g.SaveSnapshot(path); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g.removeLocally("jw")
+	for name, corrupt := range map[string][]byte{
+		"truncated": data[:len(data)-1],
+		"flipped":   append([]byte{data[0] ^ 0xff}, data[1:]...),
+		"empty":     nil,
+	} {
+		if err := os.WriteFile(path, corrupt, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if n, err := g.LoadSnapshot(path); !errors.Is(err, ErrCorruptSnapshot) || n != 0 {
+			t.Fatalf("%s: LoadSnapshot = %d, %v, want ErrCorruptSnapshot", name, n, err)
+		}
+	}
+	if _, ok := g.mainCache.get("jw"); ok {
+		t.Fatalf("corrupt snapshot should not load any entry")
+	}
+}
@@ -0,0 +1,111 @@
+package cache
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync/atomic"
+)
+
+// stats Group 内部的统计计数器，使用原子操作保证并发安全
+type stats struct {
+	gets           atomic.Int64 // Get 调用次数
+	hits           atomic.Int64 // mainCache 或 hotCache 命中次数
+	staleHits      atomic.Int64 // 命中已过期的旧值的次数，包含在 hits 中
+	refreshes      atomic.Int64 // 后台重新加载的次数
+	loads          atomic.Int64 // 未命中而进入 load 的次数
+	loadsDeduped   atomic.Int64 // 被 singleflight 合并、未实际加载的次数
+	peerLoads      atomic.Int64 // 从远程节点加载成功次数
+	peerErrors     atomic.Int64 // 从远程节点加载失败次数
+	localLoads     atomic.Int64 // 通过 Getter 回源成功次数
+	localLoadErrs  atomic.Int64 // 通过 Getter 回源失败次数
+	serverRequests atomic.Int64 // 处理其他节点请求的次数
+}
+
+// Stats 是 Group 统计信息的快照
+type Stats struct {
+	Gets           int64
+	Hits           int64
+	StaleHits      int64
+	Refreshes      int64
+	Loads          int64
+	LoadsDeduped   int64
+	PeerLoads      int64
+	PeerErrors     int64
+	LocalLoads     int64
+	LocalLoadErrs  int64
+	ServerRequests int64
+	// 以下字段为 mainCache 与 hotCache 之和
+	Evictions int64
+	Bytes     int64
+	Items     int64
+}
+
+// Stats 返回 Group 当前的统计信息
+func (g *Group) Stats() Stats {
+	s := Stats{
+		Gets:           g.stats.gets.Load(),
+		Hits:           g.stats.hits.Load(),
+		StaleHits:      g.stats.staleHits.Load(),
+		Refreshes:      g.stats.refreshes.Load(),
+		Loads:          g.stats.loads.Load(),
+		LoadsDeduped:   g.stats.loadsDeduped.Load(),
+		PeerLoads:      g.stats.peerLoads.Load(),
+		PeerErrors:     g.stats.peerErrors.Load(),
+		LocalLoads:     g.stats.localLoads.Load(),
+		LocalLoadErrs:  g.stats.localLoadErrs.Load(),
+		ServerRequests: g.stats.serverRequests.Load(),
+	}
+	for _, cs := range []CacheStats{g.mainCache.stats(), g.hotCache.stats()} {
+		s.Evictions += cs.Evictions
+		s.Bytes += cs.Bytes
+		s.Items += cs.Items
+	}
+	return s
+}
+
+// metric 描述一个导出的指标
+type metric struct {
+	name  string
+	help  string
+	typ   string // counter 或 gauge
+	value func(s Stats) int64
+}
+
+var metrics = []metric{
+	{"cache_gets_total", "Total number of Get requests.", "counter", func(s Stats) int64 { return s.Gets }},
+	{"cache_hits_total", "Total number of cache hits.", "counter", func(s Stats) int64 { return s.Hits }},
+	{"cache_stale_hits_total", "Total number of hits that returned a stale value.", "counter", func(s Stats) int64 { return s.StaleHits }},
+	{"cache_refreshes_total", "Total number of background reloads.", "counter", func(s Stats) int64 { return s.Refreshes }},
+	{"cache_loads_total", "Total number of cache misses that went to load.", "counter", func(s Stats) int64 { return s.Loads }},
+	{"cache_loads_deduped_total", "Total number of loads merged by singleflight.", "counter", func(s Stats) int64 { return s.LoadsDeduped }},
+	{"cache_peer_loads_total", "Total number of successful loads from peers.", "counter", func(s Stats) int64 { return s.PeerLoads }},
+	{"cache_peer_errors_total", "Total number of failed loads from peers.", "counter", func(s Stats) int64 { return s.PeerErrors }},
+	{"cache_local_loads_total", "Total number of successful loads from the Getter.", "counter", func(s Stats) int64 { return s.LocalLoads }},
+	{"cache_local_load_errors_total", "Total number of failed loads from the Getter.", "counter", func(s Stats) int64 { return s.LocalLoadErrs }},
+	{"cache_server_requests_total", "Total number of requests served for peers.", "counter", func(s Stats) int64 { return s.ServerRequests }},
+	{"cache_evictions_total", "Total number of evicted entries.", "counter", func(s Stats) int64 { return s.Evictions }},
+	{"cache_bytes", "Current number of bytes held by the cache.", "gauge", func(s Stats) int64 { return s.Bytes }},
+	{"cache_items", "Current number of items held by the cache.", "gauge", func(s Stats) int64 { return s.Items }},
+}
+
+// writeMetrics 以 Prometheus 文本格式输出所有 Group 的统计信息
+func writeMetrics(w io.Writer) {
+	mu.RLock()
+	names := make([]string, 0, len(groups))
+	all := make(map[string]Stats, len(groups))
+	for name, g := range groups {
+		names = append(names, name)
+		all[name] = g.Stats()
+	}
+	mu.RUnlock()
+	sort.Strings(names)
+
+	for _, m := range metrics {
+		fmt.Fprintf(w, "# HELP %s %s\n", m.name, m.help)
+		fmt.Fprintf(w, "# TYPE %s %s\n", m.name, m.typ)
+		for _, name := range names {
+			fmt.Fprintf(w, "%s{group=%q} %d\n", m.name, name, m.value(all[name]))
+		}
+	}
+}
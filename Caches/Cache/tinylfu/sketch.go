@@ -0,0 +1,83 @@
+package tinylfu
+
+import "hash/fnv"
+
+// sketchDepth Count-Min Sketch 的行数
+const sketchDepth = 4
+
+// cmSketch 使用 4 位计数器的 Count-Min Sketch，用很小的内存近似统计 key 的访问频率
+// 每累计 resetAt 次访问，所有计数器减半，使频率随时间衰减，旧的热点可以被新的热点替换
+type cmSketch struct {
+	rows    [sketchDepth][]byte // 每个字节保存两个 4 位计数器
+	mask    uint64
+	added   int
+	resetAt int
+}
+
+// newSketch 为大约 entries 个缓存项创建 sketch
+// 宽度取 entries 的 4 倍以降低哈希冲突，累计 10*entries 次访问后衰减一次
+func newSketch(entries int) *cmSketch {
+	w := 16
+	for w < 4*entries {
+		w <<= 1
+	}
+	s := &cmSketch{
+		mask:    uint64(w - 1),
+		resetAt: 10 * entries,
+	}
+	for i := range s.rows {
+		s.rows[i] = make([]byte, w/2)
+	}
+	return s
+}
+
+func hashKey(key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return h.Sum64()
+}
+
+// index 使用双重哈希为第 i 行计算计数器下标
+func (s *cmSketch) index(h uint64, i int) uint64 {
+	h1, h2 := h, h>>32|h<<32
+	return (h1 + uint64(i)*h2) & s.mask
+}
+
+func (s *cmSketch) get(row int, idx uint64) byte {
+	return (s.rows[row][idx/2] >> ((idx & 1) * 4)) & 0x0f
+}
+
+// Increment 记录 key 的一次访问
+func (s *cmSketch) Increment(key string) {
+	h := hashKey(key)
+	for i := range s.rows {
+		idx := s.index(h, i)
+		if s.get(i, idx) < 15 {
+			s.rows[i][idx/2] += 1 << ((idx & 1) * 4)
+		}
+	}
+	s.added++
+	if s.added >= s.resetAt {
+		s.reset()
+	}
+}
+
+// Estimate 返回 key 访问频率的估计值（各行计数器的最小值）
+func (s *cmSketch) Estimate(key string) byte {
+	h := hashKey(key)
+	est := byte(15)
+	for i := range s.rows {
+		est = min(est, s.get(i, s.index(h, i)))
+	}
+	return est
+}
+
+// reset 所有计数器减半
+func (s *cmSketch) reset() {
+	for i := range s.rows {
+		for j := range s.rows[i] {
+			s.rows[i][j] = (s.rows[i][j] >> 1) & 0x77
+		}
+	}
+	s.added /= 2
+}
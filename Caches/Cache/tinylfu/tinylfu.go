@@ -0,0 +1,279 @@
+// Package tinylfu 实现 W-TinyLFU 淘汰策略
+//
+// 缓存分为两部分：
+//   - window：约占 1% 容量的 LRU，新数据先进入这里，吸收突发访问
+//   - main：其余容量的分段 LRU（SLRU），由 probation 和 protected 两段组成，
+//     probation 中的数据再次被访问后晋升到 protected
+//
+// window 淘汰出的数据需要与 main 中即将被淘汰的数据比较访问频率（由 Count-Min Sketch 估计），
+// 频率更高者才能留在 main 中。这样一次性的扫描流量无法挤掉真正的热点数据。
+package tinylfu
+
+import (
+	"cache/lru"
+	"container/list"
+	"time"
+)
+
+// Value 与 lru.Value 相同，使用 Len() 方法返回占用的内存大小
+type Value = lru.Value
+
+const (
+	// windowPercent window 占总容量的百分比
+	windowPercent = 1
+	// protectedPercent protected 占 main 容量的百分比
+	protectedPercent = 80
+	// bytesPerCounter 估算 sketch 宽度时，假设每个缓存项的平均大小
+	bytesPerCounter = 16
+)
+
+// segment 表示节点所在的分段
+type segment uint8
+
+const (
+	window segment = iota
+	probation
+	protected
+)
+
+// Cache W-TinyLFU 缓存，front 为最近访问
+type Cache struct {
+	maxBytes       int64
+	windowBytes    int64 // window 容量上限
+	protectedBytes int64 // protected 容量上限
+
+	lists  [3]*list.List // 按 segment 下标
+	nbytes [3]int64      // 各段当前占用
+	cache  map[string]*list.Element
+	sketch *cmSketch
+
+	// 淘汰回调，可选
+	OnEvicted func(key string, value Value)
+}
+
+type entry struct {
+	key    string
+	value  Value
+	expire time.Time // 过期时间，零值表示永不过期
+	seg    segment
+}
+
+func (e *entry) expired(now time.Time) bool {
+	return !e.expire.IsZero() && now.After(e.expire)
+}
+
+func (e *entry) size() int64 {
+	return int64(len(e.key)) + int64(e.value.Len())
+}
+
+// New 创建一个新的 Cache，maxBytes 为 0 表示不限制容量
+func New(maxBytes int64, onEvicted func(string, Value)) *Cache {
+	c := &Cache{
+		maxBytes:  maxBytes,
+		cache:     make(map[string]*list.Element),
+		sketch:    newSketch(int(min(max(maxBytes/bytesPerCounter, 1), 1<<20))),
+		OnEvicted: onEvicted,
+	}
+	for i := range c.lists {
+		c.lists[i] = list.New()
+	}
+	if maxBytes > 0 {
+		c.windowBytes = max(maxBytes*windowPercent/100, 1)
+		c.protectedBytes = (maxBytes - c.windowBytes) * protectedPercent / 100
+	}
+	return c
+}
+
+// Get 查找 key，无论命中与否都计入访问频率；已过期的节点视为未命中并删除
+func (c *Cache) Get(key string) (value Value, ok bool) {
+	c.sketch.Increment(key)
+	ele, ok := c.cache[key]
+	if !ok {
+		return nil, false
+	}
+	kv := ele.Value.(*entry)
+	if kv.expired(time.Now()) {
+		c.removeElement(ele)
+		return nil, false
+	}
+	c.access(ele)
+	return kv.value, true
+}
+
+// Peek 查找 key 对应的值，不更新访问记录和访问频率，已过期的节点视为不存在
+func (c *Cache) Peek(key string) (value Value, ok bool) {
+	if ele, ok := c.cache[key]; ok {
+		kv := ele.Value.(*entry)
+		if !kv.expired(time.Now()) {
+			return kv.value, true
+		}
+	}
+	return nil, false
+}
+
+// access 命中后调整节点位置：probation 中的节点晋升到 protected
+func (c *Cache) access(ele *list.Element) {
+	kv := ele.Value.(*entry)
+	if kv.seg != probation || c.maxBytes == 0 {
+		c.lists[kv.seg].MoveToFront(ele)
+		return
+	}
+	c.move(ele, protected)
+	// protected 超出容量时，把最久未访问的节点降级回 probation
+	for c.nbytes[protected] > c.protectedBytes {
+		c.move(c.lists[protected].Back(), probation)
+	}
+}
+
+// move 将节点移动到另一分段的 front
+func (c *Cache) move(ele *list.Element, to segment) *list.Element {
+	kv := ele.Value.(*entry)
+	c.lists[kv.seg].Remove(ele)
+	c.nbytes[kv.seg] -= kv.size()
+	kv.seg = to
+	ele = c.lists[to].PushFront(kv)
+	c.nbytes[to] += kv.size()
+	c.cache[kv.key] = ele
+	return ele
+}
+
+// Add 添加/更新节点，永不过期
+func (c *Cache) Add(key string, value Value) {
+	c.AddWithExpire(key, value, time.Time{})
+}
+
+// AddWithExpire 添加/更新节点并指定过期时间，expire 为零值时表示永不过期
+// 新节点先放入 window，window 溢出的节点经过频率比较后才能进入 main
+func (c *Cache) AddWithExpire(key string, value Value, expire time.Time) {
+	if ele, ok := c.cache[key]; ok {
+		kv := ele.Value.(*entry)
+		c.nbytes[kv.seg] += int64(value.Len()) - int64(kv.value.Len())
+		kv.value = value
+		kv.expire = expire
+		c.access(ele)
+	} else {
+		// 写入同样计入访问频率，否则只写不读的数据永远无法进入 main
+		c.sketch.Increment(key)
+		kv := &entry{key: key, value: value, expire: expire, seg: window}
+		c.cache[key] = c.lists[window].PushFront(kv)
+		c.nbytes[window] += kv.size()
+	}
+	if c.maxBytes == 0 {
+		return
+	}
+
+	for c.nbytes[window] > c.windowBytes {
+		c.admit(c.lists[window].Back())
+	}
+	// 更新节点可能使 main 超出容量
+	for c.mainBytes() > c.maxBytes-c.windowBytes {
+		c.RemoveOldest()
+	}
+}
+
+// mainBytes 返回 main（probation + protected）当前占用
+func (c *Cache) mainBytes() int64 {
+	return c.nbytes[probation] + c.nbytes[protected]
+}
+
+// victim 返回 main 中下一个将被淘汰的节点
+func (c *Cache) victim() *list.Element {
+	if ele := c.lists[probation].Back(); ele != nil {
+		return ele
+	}
+	return c.lists[protected].Back()
+}
+
+// admit 决定 window 淘汰出的候选节点能否进入 main
+func (c *Cache) admit(candidate *list.Element) {
+	ckv := candidate.Value.(*entry)
+	limit := c.maxBytes - c.windowBytes
+	for c.mainBytes()+ckv.size() > limit {
+		victim := c.victim()
+		if victim == nil {
+			break
+		}
+		// 候选者频率不高于被淘汰者时，淘汰候选者
+		if c.sketch.Estimate(ckv.key) <= c.sketch.Estimate(victim.Value.(*entry).key) {
+			c.removeElement(candidate)
+			return
+		}
+		c.removeElement(victim)
+	}
+	if ckv.size() > limit {
+		c.removeElement(candidate)
+		return
+	}
+	c.move(candidate, probation)
+}
+
+// RemoveOldest 淘汰 main 中最久未访问的节点，main 为空时淘汰 window 中的
+func (c *Cache) RemoveOldest() {
+	ele := c.victim()
+	if ele == nil {
+		ele = c.lists[window].Back()
+	}
+	if ele != nil {
+		c.removeElement(ele)
+	}
+}
+
+// Remove 删除指定 key 对应的节点，返回 key 是否存在
+func (c *Cache) Remove(key string) bool {
+	if ele, ok := c.cache[key]; ok {
+		c.removeElement(ele)
+		return true
+	}
+	return false
+}
+
+// RemoveExpired 清理所有已过期的节点，返回清理的数量
+func (c *Cache) RemoveExpired() int {
+	now := time.Now()
+	n := 0
+	for _, ele := range c.cache {
+		if ele.Value.(*entry).expired(now) {
+			c.removeElement(ele)
+			n++
+		}
+	}
+	return n
+}
+
+// Range 依次遍历 probation、protected、window 中未过期的节点，段内从旧到新，
+// fn 返回 false 时停止遍历
+func (c *Cache) Range(fn func(key string, value Value, expire time.Time) bool) {
+	now := time.Now()
+	for _, seg := range []segment{probation, protected, window} {
+		for ele := c.lists[seg].Back(); ele != nil; ele = ele.Prev() {
+			kv := ele.Value.(*entry)
+			if kv.expired(now) {
+				continue
+			}
+			if !fn(kv.key, kv.value, kv.expire) {
+				return
+			}
+		}
+	}
+}
+
+// removeElement 删除节点，并调用淘汰回调
+func (c *Cache) removeElement(ele *list.Element) {
+	kv := ele.Value.(*entry)
+	c.lists[kv.seg].Remove(ele)
+	c.nbytes[kv.seg] -= kv.size()
+	delete(c.cache, kv.key)
+	if c.OnEvicted != nil {
+		c.OnEvicted(kv.key, kv.value)
+	}
+}
+
+// Len 返回当前缓存的节点数量
+func (c *Cache) Len() int {
+	return len(c.cache)
+}
+
+// Bytes 返回当前缓存占用的字节数
+func (c *Cache) Bytes() int64 {
+	return c.nbytes[window] + c.nbytes[probation] + c.nbytes[protected]
+}
@@ -0,0 +1,79 @@
+package tinylfu
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+type String string
+
+func (d String) Len() int {
+	return len(d)
+}
+
+func TestGet(t *testing.T) {
+	c := New(int64(0), nil)
+	c.Add("key1", String("456"))
+
+	if v, ok := c.Get("key1"); !ok || string(v.(String)) != "456" {
+		t.Fatalf("cache hit key1=456 failed")
+	}
+	if _, ok := c.Get("key2"); ok {
+		t.Fatalf("cache miss key2 failed")
+	}
+}
+
+// TestScanResistance 穿插在热点访问之间的大量一次性扫描不会挤掉热点数据
+// 热点 key 的重用距离约为 1000 个 key，超过容量（约 600 个），LRU 在此场景下几乎全部失效
+func TestScanResistance(t *testing.T) {
+	c := New(int64(10000), nil)
+	get := func(key string) bool {
+		if _, ok := c.Get(key); ok {
+			return true
+		}
+		c.Add(key, String("0123456789"))
+		return false
+	}
+
+	hits, total := 0, 0
+	for i := 0; i < 20000; i++ {
+		if get("scan" + strconv.Itoa(i)) {
+			t.Fatalf("scan key should never hit")
+		}
+		if i%20 == 0 {
+			hit := get("hot" + strconv.Itoa(i/20%50))
+			if i >= 10000 {
+				total++
+				if hit {
+					hits++
+				}
+			}
+		}
+	}
+
+	if hits < total*9/10 {
+		t.Fatalf("hot keys hit %d of %d after warm up", hits, total)
+	}
+	if c.Bytes() > 10000 {
+		t.Fatalf("cache exceeds max bytes: %d", c.Bytes())
+	}
+}
+
+func TestExpireAndRemove(t *testing.T) {
+	evicted := 0
+	c := New(int64(0), func(string, Value) { evicted++ })
+	c.AddWithExpire("key1", String("1"), time.Now().Add(-time.Second))
+	c.AddWithExpire("key2", String("2"), time.Now().Add(-time.Second))
+	c.Add("key3", String("3"))
+
+	if _, ok := c.Get("key1"); ok {
+		t.Fatalf("expired key1 should miss")
+	}
+	if n := c.RemoveExpired(); n != 1 {
+		t.Fatalf("RemoveExpired removed %d, expect 1", n)
+	}
+	if !c.Remove("key3") || c.Len() != 0 || c.Bytes() != 0 || evicted != 3 {
+		t.Fatalf("Remove key3 failed, len %d bytes %d evicted %d", c.Len(), c.Bytes(), evicted)
+	}
+}
@@ -0,0 +1,162 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ErrCodec 编码或解码缓存值失败，TypedGroup 返回的编解码错误都包装了该错误
+var ErrCodec = errors.New("cache: codec error")
+
+// Codec 在类型 T 与缓存中保存的字节之间转换
+// 同一个 Group 在所有节点上必须使用相同的 Codec，否则从其他节点取得的值无法解码
+type Codec[T any] interface {
+	Marshal(v T) ([]byte, error)
+	Unmarshal(data []byte, v *T) error
+}
+
+// JSONCodec 使用 encoding/json 编码，便于通过管理接口等直接查看缓存内容
+type JSONCodec[T any] struct{}
+
+func (JSONCodec[T]) Marshal(v T) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JSONCodec[T]) Unmarshal(data []byte, v *T) error {
+	return json.Unmarshal(data, v)
+}
+
+// GobCodec 使用 encoding/gob 编码，每个值都带有类型信息，适合字段较多的结构体
+type GobCodec[T any] struct{}
+
+func (GobCodec[T]) Marshal(v T) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (GobCodec[T]) Unmarshal(data []byte, v *T) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// BinaryCodec 使用 encoding/binary 以大端序编码，T 必须是定长类型
+// （数值、布尔值，或只包含这些类型的数组和结构体），编码结果最紧凑
+type BinaryCodec[T any] struct{}
+
+func (BinaryCodec[T]) Marshal(v T) ([]byte, error) {
+	return binary.Append(nil, binary.BigEndian, v)
+}
+
+func (BinaryCodec[T]) Unmarshal(data []byte, v *T) error {
+	n, err := binary.Decode(data, binary.BigEndian, v)
+	if err != nil {
+		return err
+	}
+	if n != len(data) {
+		return fmt.Errorf("%d trailing bytes", len(data)-n)
+	}
+	return nil
+}
+
+// TypedGetterFunc 回源加载类型为 T 的值，返回的错误包装了 ErrNotFound 时表示 key 不存在
+type TypedGetterFunc[T any] func(ctx context.Context, key string) (T, error)
+
+// TypedGroup 在 Group 之上以 Codec 编解码，读写类型为 T 的值
+// 缓存、回源、节点间通信等仍由底层的 Group 完成
+type TypedGroup[T any] struct {
+	group *Group
+	codec Codec[T]
+}
+
+// NewTypedGroup 创建一个新的缓存组，getter 返回的值以 codec 编码后缓存，opts 与错误见 NewGroup
+func NewTypedGroup[T any](name string, codec Codec[T], getter TypedGetterFunc[T], opts ...GroupOption) (*TypedGroup[T], error) {
+	if getter == nil {
+		panic("nil Getter")
+	}
+	g, err := NewGroup(name, ContextGetterFunc(func(ctx context.Context, key string) ([]byte, error) {
+		v, err := getter(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		data, err := codec.Marshal(v)
+		if err != nil {
+			return nil, fmt.Errorf("%w: encode %s: %w", ErrCodec, key, err)
+		}
+		return data, nil
+	}), opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &TypedGroup[T]{group: g, codec: codec}, nil
+}
+
+// Typed 以 codec 包装已有的 Group，其 Getter 返回的数据需要由同样的 codec 编码
+func Typed[T any](g *Group, codec Codec[T]) *TypedGroup[T] {
+	return &TypedGroup[T]{group: g, codec: codec}
+}
+
+// Group 返回底层的 Group，用于注册节点、查看统计等
+func (t *TypedGroup[T]) Group() *Group {
+	return t.group
+}
+
+// Get 读取 key 对应的值
+func (t *TypedGroup[T]) Get(key string) (T, error) {
+	return t.GetContext(context.Background(), key)
+}
+
+// GetContext 与 Get 相同，但 ctx 取消或超时后立即返回 ctx.Err()
+func (t *TypedGroup[T]) GetContext(ctx context.Context, key string) (T, error) {
+	var v T
+	view, err := t.group.GetContext(ctx, key)
+	if err != nil {
+		return v, err
+	}
+	return t.decode(key, view)
+}
+
+// GetMulti 批量读取，与 Group.GetMultiContext 相同，不存在的 key 不出现在结果中
+func (t *TypedGroup[T]) GetMulti(ctx context.Context, keys []string) (map[string]T, error) {
+	views, err := t.group.GetMultiContext(ctx, keys)
+	values := make(map[string]T, len(views))
+	for key, view := range views {
+		v, decodeErr := t.decode(key, view)
+		if decodeErr != nil {
+			if err == nil {
+				err = decodeErr
+			}
+			continue
+		}
+		values[key] = v
+	}
+	return values, err
+}
+
+// Set 编码 value 并写入缓存，见 Group.Set
+func (t *TypedGroup[T]) Set(key string, value T) error {
+	data, err := t.codec.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("%w: encode %s: %w", ErrCodec, key, err)
+	}
+	return t.group.Set(key, data)
+}
+
+// Remove 删除 key 对应的缓存，见 Group.Remove
+func (t *TypedGroup[T]) Remove(key string) error {
+	return t.group.Remove(key)
+}
+
+func (t *TypedGroup[T]) decode(key string, view ByteView) (T, error) {
+	var v T
+	if err := t.codec.Unmarshal(view.ByteSlice(), &v); err != nil {
+		return v, fmt.Errorf("%w: decode %s: %w", ErrCodec, key, err)
+	}
+	return v, nil
+}
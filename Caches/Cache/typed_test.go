@@ -0,0 +1,99 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+type student struct {
+	Name  string
+	Score int
+}
+
+type point struct {
+	X, Y int32
+}
+
+func TestCodecs(t *testing.T) {
+	s := student{"jw", 630}
+	for name, codec := range map[string]Codec[student]{
+		"json": JSONCodec[student]{},
+		"gob":  GobCodec[student]{},
+	} {
+		data, err := codec.Marshal(s)
+		if err != nil {
+			t.Fatalf("%s Marshal: %v", name, err)
+		}
+		var got student
+		if err := codec.Unmarshal(data, &got); err != nil || got != s {
+			t.Fatalf("%s round trip = %+v, %v", name, got, err)
+		}
+	}
+
+	codec := BinaryCodec[point]{}
+	data, err := codec.Marshal(point{1, -2})
+	if err != nil || len(data) != 8 {
+		t.Fatalf("binary Marshal = %v, %v", data, err)
+	}
+	var p point
+	if err := codec.Unmarshal(data, &p); err != nil || p != (point{1, -2}) {
+		t.Fatalf("binary round trip = %+v, %v", p, err)
+	}
+	if err := codec.Unmarshal(append(data, 0), &p); err == nil {
+		t.Fatalf("binary Unmarshal should reject trailing bytes")
+	}
+}
+
+func TestTypedGroup(t *testing.T) {
+	loads := map[string]int{}
+	g, err := NewTypedGroup("typed", JSONCodec[student]{},
+		func(_ context.Context, key string) (student, error) {
+			loads[key]++
+			if key == "unknown" {
+				return student{}, fmt.Errorf("%w: %s", ErrNotFound, key)
+			}
+			return student{Name: key, Score: len(key)}, nil
+		}, WithCacheBytes(2<<10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer DestroyGroup("typed")
+
+	for i := 0; i < 2; i++ {
+		if s, err := g.Get("boyue"); err != nil || s != (student{"boyue", 5}) {
+			t.Fatalf("Get = %+v, %v", s, err)
+		}
+	}
+	if loads["boyue"] != 1 {
+		t.Fatalf("boyue loaded %d times, want 1", loads["boyue"])
+	}
+	if _, err := g.Get("unknown"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get unknown err = %v, want ErrNotFound", err)
+	}
+
+	if err := g.Set("Sam", student{"Sam", 567}); err != nil {
+		t.Fatal(err)
+	}
+	values, err := g.GetMulti(context.Background(), []string{"Sam", "jw", "unknown"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]student{"Sam": {"Sam", 567}, "jw": {"jw", 2}}
+	if !reflect.DeepEqual(values, want) {
+		t.Fatalf("GetMulti = %v, want %v", values, want)
+	}
+
+	// 底层 Group 中的数据不是合法的 JSON 时返回 ErrCodec
+	if err := g.Group().Set("bad", []byte("{")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := g.Get("bad"); !errors.Is(err, ErrCodec) {
+		t.Fatalf("Get bad err = %v, want ErrCodec", err)
+	}
+	if _, err := Typed(GetGroup("typed"), BinaryCodec[point]{}).Get("Sam"); !errors.Is(err, ErrCodec) {
+		t.Fatalf("Get with mismatched codec err = %v, want ErrCodec", err)
+	}
+}
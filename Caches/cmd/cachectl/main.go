@@ -0,0 +1,175 @@
+// cachectl 通过节点的管理接口（/_admin/）查看和清理缓存
+//
+//	cachectl [-addr http://localhost:8001] groups
+//	cachectl [-addr http://localhost:8001] stats <group>
+//	cachectl [-addr http://localhost:8001] get <group> <key>
+//	cachectl [-addr http://localhost:8001] del <group> <key>
+//	cachectl [-addr http://localhost:8001] purge <group>
+//	cachectl [-addr http://localhost:8001] invalidate <group>
+//
+// 节点需以 -admin 启动才会提供管理接口；
+// 节点开启了 HTTPS 或请求签名时，使用 -cert、-key、-ca 和 -secret 提供与节点相同的配置
+package main
+
+import (
+	"cache"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, `usage: cachectl [-addr url] <command> [args]
+
+commands:
+  groups               list groups on the node
+  stats <group>        show statistics of a group
+  get <group> <key>    show a cached key without loading it
+  del <group> <key>    delete a key from the cluster
+  purge <group>        drop all cached entries of a group on the node
+  invalidate <group>   invalidate all keys of a group on every node`)
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func main() {
+	addr := flag.String("addr", "http://localhost:8001", "base url of the cache node")
+	certFile := flag.String("cert", "", "client certificate file for mutual TLS")
+	keyFile := flag.String("key", "", "client private key file for mutual TLS")
+	caFile := flag.String("ca", "", "CA certificate file of the cluster")
+	secret := flag.String("secret", "", "shared secret used to sign requests")
+	flag.Usage = usage
+	flag.Parse()
+
+	args := flag.Args()
+	if len(args) == 0 {
+		usage()
+	}
+
+	var rt http.RoundTripper = http.DefaultTransport
+	if *certFile != "" {
+		cfg, err := cache.MutualTLSConfig(*certFile, *keyFile, *caFile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "cachectl:", err)
+			os.Exit(1)
+		}
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		t.TLSClientConfig = cfg
+		rt = t
+	}
+	if *secret != "" {
+		rt = cache.NewSigningTransport(rt, []byte(*secret))
+	}
+	c := &client{addr: *addr, http: &http.Client{Transport: rt}}
+
+	var err error
+	switch cmd := args[0]; {
+	case cmd == "groups" && len(args) == 1:
+		err = c.groups()
+	case cmd == "stats" && len(args) == 2:
+		err = c.stats(args[1])
+	case cmd == "get" && len(args) == 3:
+		err = c.get(args[1], args[2])
+	case cmd == "del" && len(args) == 3:
+		err = c.do(http.MethodDelete, keyPath(args[1], args[2]), nil)
+	case cmd == "purge" && len(args) == 2:
+		err = c.do(http.MethodPost, groupPath(args[1])+"/purge", nil)
+	case cmd == "invalidate" && len(args) == 2:
+		err = c.do(http.MethodPost, groupPath(args[1])+"/invalidate", nil)
+	default:
+		usage()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "cachectl:", err)
+		os.Exit(1)
+	}
+}
+
+func groupPath(group string) string {
+	return "/_admin/groups/" + url.PathEscape(group)
+}
+
+func keyPath(group, key string) string {
+	return groupPath(group) + "/keys/" + url.PathEscape(key)
+}
+
+// client 管理接口的客户端
+type client struct {
+	addr string
+	http *http.Client
+}
+
+// do 发起请求，out 不为空时将响应解码到 out
+func (c *client) do(method, path string, out interface{}) error {
+	req, err := http.NewRequest(method, c.addr+path, nil)
+	if err != nil {
+		return err
+	}
+	res, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 300 {
+		var e struct {
+			Error string `json:"error"`
+		}
+		body, _ := io.ReadAll(res.Body)
+		if json.Unmarshal(body, &e) == nil && e.Error != "" {
+			return fmt.Errorf("%s: %s", res.Status, e.Error)
+		}
+		return fmt.Errorf("%s", res.Status)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(out)
+}
+
+func (c *client) groups() error {
+	var infos []cache.GroupInfo
+	if err := c.do(http.MethodGet, "/_admin/groups", &infos); err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "GROUP\tITEMS\tBYTES\tGETS\tHITS")
+	for _, info := range infos {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\n", info.Name, info.Stats.Items, info.Stats.Bytes, info.Stats.Gets, info.Stats.Hits)
+	}
+	return w.Flush()
+}
+
+func (c *client) stats(group string) error {
+	var info cache.GroupInfo
+	if err := c.do(http.MethodGet, groupPath(group), &info); err != nil {
+		return err
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(info)
+}
+
+func (c *client) get(group, key string) error {
+	var info cache.KeyInfo
+	if err := c.do(http.MethodGet, keyPath(group, key), &info); err != nil {
+		return err
+	}
+	expire := "never"
+	if info.Expire != nil {
+		expire = fmt.Sprintf("%s (in %s)", info.Expire.Format(time.RFC3339), time.Until(*info.Expire).Round(time.Second))
+	}
+	fmt.Printf("cache:  %s\nexpire: %s\n", info.Cache, expire)
+	if info.NotFound {
+		fmt.Println("value:  <not found>")
+		return nil
+	}
+	fmt.Printf("value:  %s\n", info.Value)
+	return nil
+}
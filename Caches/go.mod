@@ -4,4 +4,4 @@ go 1.24.6
 
 
 require cache v0.0.0
-replace cache => ./cache
\ No newline at end of file
+replace cache => ./Cache
\ No newline at end of file
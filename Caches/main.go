@@ -2,10 +2,29 @@ package main
 
 import (
 	"cache"
+	"context"
+	"crypto/tls"
+	"errors"
 	"flag"
 	"fmt"
+	"io/fs"
 	"log"
 	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	// snapshotInterval 定时保存快照的间隔
+	snapshotInterval = time.Minute
+	// negativeTTL 不存在的 key 的缓存时间
+	negativeTTL = 5 * time.Second
+	// compressThreshold 超过该长度的值压缩后存储
+	compressThreshold = 1 << 10
 )
 
 var db = map[string]string{
@@ -15,34 +34,103 @@ var db = map[string]string{
 }
 
 func createGroup() *cache.Group {
-	return cache.NewGroup("test", 2<<10, cache.GetterFunc(
-		func(key string) ([]byte, error) {
-			log.Println("[SlowDB] search key", key)
-			if v, ok := db[key]; ok {
-				return []byte(v), nil
+	// 一次查询多个 key，未返回的 key 视为不存在
+	g, err := cache.NewGroup("test", cache.BatchGetterFunc(
+		func(_ context.Context, keys []string) (map[string][]byte, error) {
+			log.Println("[SlowDB] search keys", keys)
+			values := make(map[string][]byte, len(keys))
+			for _, key := range keys {
+				if v, ok := db[key]; ok {
+					values[key] = []byte(v)
+				}
 			}
-			return nil, fmt.Errorf("%s not exist", key)
-		}))
+			return values, nil
+		}), cache.WithCacheBytes(2<<10), cache.WithNegativeTTL(negativeTTL), cache.WithCompression(compressThreshold))
+	if err != nil {
+		log.Fatal(err)
+	}
+	return g
 }
 
-// startCacheServer 启动缓存服务器
-func startCacheServer(addr string, addrs []string, g *cache.Group) {
+// startCacheServer 启动缓存服务器，通过 gossip 从种子节点发现集群中的其他节点
+// tlsConfig 不为空时节点间使用双向认证的 HTTPS，secret 不为空时节点间请求需要签名
+func startCacheServer(addr string, seeds []string, g *cache.Group, tlsConfig *tls.Config, secret []byte, admin bool) {
 	peers := cache.NewHTTPPool(addr)
-	peers.Set(addrs...)
+	if tlsConfig != nil {
+		peers.SetTLS(tlsConfig)
+	}
+	if len(secret) > 0 {
+		peers.SetSecret(secret)
+	}
+	if admin {
+		peers.EnableAdmin()
+	}
+	peers.StartGossip(seeds...)
 
 	g.RegisterPeers(peers)
 
+	u, err := url.Parse(addr)
+	if err != nil {
+		log.Fatal(err)
+	}
 	log.Println("cache is running at", addr)
-	log.Fatal(http.ListenAndServe(addr[7:], peers))
+	server := &http.Server{Addr: u.Host, Handler: peers, TLSConfig: tlsConfig}
+	if tlsConfig != nil {
+		log.Fatal(server.ListenAndServeTLS("", ""))
+	}
+	log.Fatal(server.ListenAndServe())
+}
+
+// startRPCCacheServer 启动基于 net/rpc 的缓存服务器，节点间使用持久 TCP 连接通信
+func startRPCCacheServer(addr string, addrs []string, g *cache.Group) {
+	peers := cache.NewRPCPool(addr)
+	peers.Set(addrs...)
 
+	g.RegisterPeers(peers)
+
+	log.Println("rpc cache is running at", addr)
+	log.Fatal(peers.ListenAndServe())
+}
+
+// restoreSnapshot 在提供服务前从快照恢复缓存，并定时、退出时保存快照
+// 快照不存在或已损坏时直接冷启动
+func restoreSnapshot(path string, g *cache.Group) {
+	n, err := g.LoadSnapshot(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		log.Println("no snapshot found at", path)
+	case err != nil:
+		log.Println("skip snapshot:", err)
+	default:
+		log.Printf("restored %d entries from %s", n, path)
+	}
+	g.StartSnapshots(path, snapshotInterval)
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sig
+		g.StopSnapshots()
+		if err := g.SaveSnapshot(path); err != nil {
+			log.Println("save snapshot:", err)
+			os.Exit(1)
+		}
+		log.Println("snapshot saved to", path)
+		os.Exit(0)
+	}()
 }
 
 // startAPIServer 启动一个 API 服务器，供用户访问
-func startAPIServer(apiAddr string, g *cache.Group) {
+// secret 不为空时在 /_admin/ 提供要求签名的管理接口，否则不提供
+func startAPIServer(apiAddr string, g *cache.Group, secret []byte) {
 	http.Handle("/api", http.HandlerFunc(
 		func(w http.ResponseWriter, r *http.Request) {
 			key := r.URL.Query().Get("key")
-			view, err := g.Get(key)
+			view, err := g.GetContext(r.Context(), key)
+			if errors.Is(err, cache.ErrNotFound) {
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			}
 			if err != nil {
 				http.Error(w, err.Error(), http.StatusInternalServerError)
 				return
@@ -50,6 +138,27 @@ func startAPIServer(apiAddr string, g *cache.Group) {
 			w.Header().Set("Content-Type", "application/octet-stream")
 			w.Write(view.ByteSlice())
 		}))
+	// /api/multi?keys=a,b,c 批量查询，每行输出一个 key=value，不存在的 key 不输出
+	http.Handle("/api/multi", http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			keys := strings.Split(r.URL.Query().Get("keys"), ",")
+			values, err := g.GetMultiContext(r.Context(), keys)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "text/plain")
+			for _, key := range keys {
+				if view, ok := values[key]; ok {
+					fmt.Fprintf(w, "%s=%s\n", key, view)
+				}
+			}
+		}))
+	// 管理接口，rpc 模式下节点之间不使用 HTTP，也可以通过 API 服务器查看缓存
+	// API 服务器面向用户，不经过签名校验的请求不能访问管理接口
+	if len(secret) > 0 {
+		http.Handle("/_admin/", cache.RequireSignature(secret, cache.NewAdminHandler()))
+	}
 	log.Println("fontend server is running at", apiAddr)
 	log.Fatal(http.ListenAndServe(apiAddr[7:], nil))
 }
@@ -78,10 +187,30 @@ func main() {
 	// 定义命令行参数：
 	// -port：缓存服务器端口（默认8001）
 	// -api：是否启动API服务器（默认false）
+	// -transport：节点间通信方式，http 或 rpc（rpc 模式下监听 port+1000）
+	// -seeds：http 模式下用于发现集群的种子节点，逗号分隔
+	// -snapshot：快照文件路径，为空表示不使用快照
+	// -cert、-key、-ca：http 模式下节点间使用双向认证的 HTTPS，此时节点地址为 https://
+	// -secret：http 模式下节点间请求的签名密钥，为空表示不签名；同时用于 API 服务器上的管理接口，为空时不提供
+	// -admin：http 模式下在节点监听的地址上提供 /_admin/ 与 /_peers 接口，未设置 -secret 时任何人都能调用
 	var port int
 	var api bool
+	var transport string
+	var seeds string
+	var snapshot string
+	var certFile, keyFile, caFile string
+	var secret string
+	var admin bool
 	flag.IntVar(&port, "port", 8001, "Cache server port")
 	flag.BoolVar(&api, "api", false, "start api server")
+	flag.StringVar(&transport, "transport", "http", "peer transport: http or rpc")
+	flag.StringVar(&seeds, "seeds", "http://localhost:8001", "comma separated seed nodes for gossip")
+	flag.StringVar(&snapshot, "snapshot", "", "snapshot file used to warm up the cache on restart")
+	flag.StringVar(&certFile, "cert", "", "peer certificate file for mutual TLS")
+	flag.StringVar(&keyFile, "key", "", "peer private key file for mutual TLS")
+	flag.StringVar(&caFile, "ca", "", "CA certificate file that signs all peer certificates")
+	flag.StringVar(&secret, "secret", "", "shared secret used to sign peer requests")
+	flag.BoolVar(&admin, "admin", false, "serve /_admin/ and /_peers on the peer port")
 	flag.Parse()
 
 	apiAddr := "http://localhost:4000"
@@ -92,14 +221,29 @@ func main() {
 		8003: "http://localhost:8003",
 	}
 
-	var addrs []string
-	for _, v := range addrMap {
-		addrs = append(addrs, v)
-	}
-
 	g := createGroup()
+	if snapshot != "" {
+		restoreSnapshot(snapshot, g)
+	}
 	if api {
-		go startAPIServer(apiAddr, g)
+		go startAPIServer(apiAddr, g, []byte(secret))
+	}
+	if transport == "rpc" {
+		var rpcAddrs []string
+		for p := range addrMap {
+			rpcAddrs = append(rpcAddrs, fmt.Sprintf("localhost:%d", p+1000))
+		}
+		startRPCCacheServer(fmt.Sprintf("localhost:%d", port+1000), rpcAddrs, g)
+		return
+	}
+	scheme := "http"
+	var tlsConfig *tls.Config
+	if certFile != "" {
+		var err error
+		if tlsConfig, err = cache.MutualTLSConfig(certFile, keyFile, caFile); err != nil {
+			log.Fatal(err)
+		}
+		scheme = "https"
 	}
-	startCacheServer(addrMap[port], addrs, g)
+	startCacheServer(fmt.Sprintf("%s://localhost:%d", scheme, port), strings.Split(seeds, ","), g, tlsConfig, []byte(secret), admin)
 }